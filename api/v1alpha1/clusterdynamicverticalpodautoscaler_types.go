@@ -0,0 +1,109 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterDynamicVerticalPodAutoscalerLabel is set on the VerticalPodAutoscalers created by the controller
+// for a ClusterDynamicVerticalPodAutoscaler. Its value is the name of the owning ClusterDynamicVerticalPodAutoscaler.
+const ClusterDynamicVerticalPodAutoscalerLabel = "autoscaling.stackrox.io/cluster-dynamic-vertical-pod-autoscaler"
+
+// ClusterDynamicVerticalPodAutoscalerSpec defines the desired state of ClusterDynamicVerticalPodAutoscaler
+type ClusterDynamicVerticalPodAutoscalerSpec struct {
+	// Selects the namespaces of the targets. All namespaces are selected when omitted.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Selects the targets of the VerticalPodAutoscalers in the selected namespaces.
+	// One VerticalPodAutoscaler is managed for every matching workload, unless the workload
+	// is already targeted by a DynamicVerticalPodAutoscaler of its namespace.
+	TargetSelector TargetSelector `json:"targetSelector"`
+
+	Policies []DynamicVerticalPodAutoscalerPolicy `json:"policies,omitempty"`
+
+	// The language of the policy conditions. Defaults to expr.
+	// +optional
+	Language ConditionLanguage `json:"language,omitempty"`
+
+	// Enforce (default) writes the VerticalPodAutoscalers, DryRun only reports
+	// the changes that would be made in the status and in events.
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+
+	// A Go template computing the names of the VerticalPodAutoscalers, from the .Name
+	// of the object and the .Namespace, .Kind and .TargetName of the target.
+	// Defaults to "{{ .Name }}-{{ lower .Kind }}-{{ .TargetName }}".
+	// +optional
+	VPANameTemplate string `json:"vpaNameTemplate,omitempty"`
+
+	// Whether existing VerticalPodAutoscalers not created for this object are adopted.
+	// The fields set by the policies are taken over from the previous managers of an adopted VerticalPodAutoscaler.
+	// Defaults to Never.
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// Takes the ownership of the fields of the VerticalPodAutoscalers set by other field managers
+	// when they conflict with the policies. Conflicts are reported in the status otherwise.
+	// +optional
+	Force bool `json:"force,omitempty"`
+
+	// What happens to the VerticalPodAutoscalers when this object is deleted.
+	// Defaults to Delete.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// What happens to the VerticalPodAutoscaler of a target when no policy matches it.
+	// Defaults to Error.
+	// +optional
+	NoMatchAction NoMatchAction `json:"noMatchAction,omitempty"`
+
+	// The VerticalPodAutoscaler spec applied when no policy matches a target and the noMatchAction is Default.
+	// Expressions are not supported.
+	// +optional
+	DefaultVpaSpec *VpaSpec `json:"defaultVpaSpec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=cdvpa
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterDynamicVerticalPodAutoscaler is the Schema for the clusterdynamicverticalpodautoscalers API
+type ClusterDynamicVerticalPodAutoscaler struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterDynamicVerticalPodAutoscalerSpec `json:"spec,omitempty"`
+	Status DynamicVerticalPodAutoscalerStatus      `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterDynamicVerticalPodAutoscalerList contains a list of ClusterDynamicVerticalPodAutoscaler
+type ClusterDynamicVerticalPodAutoscalerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDynamicVerticalPodAutoscaler `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterDynamicVerticalPodAutoscaler{}, &ClusterDynamicVerticalPodAutoscalerList{})
+}
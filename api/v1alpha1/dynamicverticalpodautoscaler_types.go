@@ -18,20 +18,232 @@ package v1alpha1
 
 import (
 	autoscaling "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 )
 
+// ConditionLanguage is the language in which the policy conditions are written.
+// +kubebuilder:validation:Enum=expr;cel
+type ConditionLanguage string
+
+const (
+	// ConditionLanguageExpr evaluates conditions with https://expr-lang.org
+	ConditionLanguageExpr ConditionLanguage = "expr"
+	// ConditionLanguageCEL evaluates conditions with https://github.com/google/cel-spec
+	ConditionLanguageCEL ConditionLanguage = "cel"
+)
+
+// Mode controls whether the controller writes the VerticalPodAutoscalers.
+// +kubebuilder:validation:Enum=Enforce;DryRun
+type Mode string
+
+const (
+	// ModeEnforce creates and updates the VerticalPodAutoscalers.
+	ModeEnforce Mode = "Enforce"
+	// ModeDryRun evaluates the policies and reports the VerticalPodAutoscaler spec that would be
+	// written in the status, without creating, updating or deleting any VerticalPodAutoscaler.
+	ModeDryRun Mode = "DryRun"
+)
+
+// AdoptionPolicy controls whether the controller takes over existing VerticalPodAutoscalers
+// that it did not create.
+// +kubebuilder:validation:Enum=Never;IfUnowned;Always
+type AdoptionPolicy string
+
+const (
+	// AdoptionPolicyNever leaves existing VerticalPodAutoscalers untouched and reports a conflict.
+	AdoptionPolicyNever AdoptionPolicy = "Never"
+	// AdoptionPolicyIfUnowned adopts existing VerticalPodAutoscalers without controller,
+	// e.g. the ones created by Helm, and reports a conflict for the others.
+	AdoptionPolicyIfUnowned AdoptionPolicy = "IfUnowned"
+	// AdoptionPolicyAlways adopts existing VerticalPodAutoscalers, replacing their controller if any.
+	AdoptionPolicyAlways AdoptionPolicy = "Always"
+)
+
+// NoMatchAction controls what happens to the VerticalPodAutoscaler of a target when no policy matches it.
+// +kubebuilder:validation:Enum=Error;Keep;Delete;Default
+type NoMatchAction string
+
+const (
+	// NoMatchActionError reports an error, and retries the reconciliation with a backoff.
+	NoMatchActionError NoMatchAction = "Error"
+	// NoMatchActionKeep leaves the VerticalPodAutoscaler as it is.
+	NoMatchActionKeep NoMatchAction = "Keep"
+	// NoMatchActionDelete deletes the VerticalPodAutoscaler.
+	NoMatchActionDelete NoMatchAction = "Delete"
+	// NoMatchActionDefault applies the defaultVpaSpec to the VerticalPodAutoscaler.
+	NoMatchActionDefault NoMatchAction = "Default"
+)
+
+// DeletionPolicy controls what happens to the VerticalPodAutoscalers of an object when it is deleted.
+// +kubebuilder:validation:Enum=Delete;Orphan;ResetToOff
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the VerticalPodAutoscalers along with the object.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan removes the owner reference of the object from the VerticalPodAutoscalers,
+	// leaving them as they are.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyResetToOff sets the updateMode of the VerticalPodAutoscalers to Off before orphaning them,
+	// so that the pods are no longer evicted.
+	DeletionPolicyResetToOff DeletionPolicy = "ResetToOff"
+)
+
+// Finalizer is set on the DynamicVerticalPodAutoscalers and ClusterDynamicVerticalPodAutoscalers
+// to apply their deletionPolicy to their VerticalPodAutoscalers before they are deleted.
+const Finalizer = "autoscaling.stackrox.io/finalizer"
+
+// DynamicVerticalPodAutoscalerLabel is set on the VerticalPodAutoscalers created by the controller.
+// Its value is the name of the owning DynamicVerticalPodAutoscaler.
+const DynamicVerticalPodAutoscalerLabel = "autoscaling.stackrox.io/dynamic-vertical-pod-autoscaler"
+
 // DynamicVerticalPodAutoscalerSpec defines the desired state of DynamicVerticalPodAutoscaler
 type DynamicVerticalPodAutoscalerSpec struct {
+	// The target of the VerticalPodAutoscaler. Mutually exclusive with targetSelector.
+	// +optional
 	TargetRef *autoscaling.CrossVersionObjectReference `json:"targetRef,omitempty"`
-	Policies  []DynamicVerticalPodAutoscalerPolicy     `json:"policies,omitempty"`
+
+	// Selects the targets of the VerticalPodAutoscalers. One VerticalPodAutoscaler
+	// is managed for every matching workload. Mutually exclusive with targetRef.
+	// +optional
+	TargetSelector *TargetSelector `json:"targetSelector,omitempty"`
+
+	// The policies of the object. When a policyTemplateRef is set, they are
+	// evaluated before or after the policies of the template.
+	Policies []DynamicVerticalPodAutoscalerPolicy `json:"policies,omitempty"`
+
+	// References a DynamicVerticalPodAutoscalerPolicyTemplate of the same namespace,
+	// whose policies are evaluated along with the policies of the object.
+	// +optional
+	PolicyTemplateRef *PolicyTemplateReference `json:"policyTemplateRef,omitempty"`
+
+	// The language of the policy conditions. Defaults to expr.
+	// +optional
+	Language ConditionLanguage `json:"language,omitempty"`
+
+	// Enforce (default) writes the VerticalPodAutoscalers, DryRun only reports
+	// the changes that would be made in the status and in events.
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+
+	// The name of the VerticalPodAutoscaler of the targetRef. Defaults to the name of the object.
+	// +optional
+	VPAName string `json:"vpaName,omitempty"`
+
+	// A Go template computing the names of the VerticalPodAutoscalers of the targetSelector,
+	// from the .Name of the object and the .Namespace, .Kind and .TargetName of the target.
+	// Defaults to "{{ .Name }}-{{ lower .Kind }}-{{ .TargetName }}".
+	// +optional
+	VPANameTemplate string `json:"vpaNameTemplate,omitempty"`
+
+	// Whether existing VerticalPodAutoscalers not created for this object are adopted.
+	// The fields set by the policies are taken over from the previous managers of an adopted VerticalPodAutoscaler.
+	// Defaults to Never.
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// Takes the ownership of the fields of the VerticalPodAutoscalers set by other field managers
+	// when they conflict with the policies. Conflicts are reported in the status otherwise.
+	// +optional
+	Force bool `json:"force,omitempty"`
+
+	// What happens to the VerticalPodAutoscalers when this object is deleted.
+	// Defaults to Delete.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// What happens to the VerticalPodAutoscaler of a target when no policy matches it.
+	// Defaults to Error.
+	// +optional
+	NoMatchAction NoMatchAction `json:"noMatchAction,omitempty"`
+
+	// The VerticalPodAutoscaler spec applied when no policy matches a target and the noMatchAction is Default.
+	// Expressions are not supported.
+	// +optional
+	DefaultVpaSpec *VpaSpec `json:"defaultVpaSpec,omitempty"`
+}
+
+// LocalPoliciesPlacement is the placement of the policies of an object relative to the policies of its template.
+// +kubebuilder:validation:Enum=Prepend;Append
+type LocalPoliciesPlacement string
+
+const (
+	// LocalPoliciesPrepend evaluates the policies of the object before the policies of the template.
+	LocalPoliciesPrepend LocalPoliciesPlacement = "Prepend"
+	// LocalPoliciesAppend evaluates the policies of the object after the policies of the template.
+	LocalPoliciesAppend LocalPoliciesPlacement = "Append"
+)
+
+// PolicyTemplateReference references a DynamicVerticalPodAutoscalerPolicyTemplate.
+type PolicyTemplateReference struct {
+	// The name of the DynamicVerticalPodAutoscalerPolicyTemplate.
+	Name string `json:"name"`
+
+	// Whether the policies of the object are evaluated before (Prepend) or after (Append)
+	// the policies of the template. Defaults to Append.
+	// +optional
+	LocalPolicies LocalPoliciesPlacement `json:"localPolicies,omitempty"`
+}
+
+// TargetSelector selects workloads of the given kinds by label.
+type TargetSelector struct {
+	// The kinds of workloads to select.
+	// +kubebuilder:validation:MinItems=1
+	Kinds []TargetKind `json:"kinds"`
+
+	// The label selector matched against the workloads.
+	// An empty selector matches every workload of the given kinds.
+	Selector *metav1.LabelSelector `json:"selector"`
+}
+
+// TargetKind identifies a kind of workload.
+type TargetKind struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
 }
 
 type DynamicVerticalPodAutoscalerPolicy struct {
-	Condition string  `json:"condition,omitempty"`
-	Skip      bool    `json:"skip,omitempty"`
-	VpaSpec   VpaSpec `json:"vpaSpec,omitempty"`
+	// Name is an optional human-readable identifier for the policy.
+	// It is reported in the status when the policy is matched.
+	// +optional
+	Name      string `json:"name,omitempty"`
+	Condition string `json:"condition,omitempty"`
+	// The language of the condition. Overrides the language of the DynamicVerticalPodAutoscaler.
+	// +optional
+	Language ConditionLanguage `json:"language,omitempty"`
+	Skip     bool              `json:"skip,omitempty"`
+	VpaSpec  VpaSpec           `json:"vpaSpec,omitempty"`
+
+	// Restricts the policy to recurring time windows. When set, the policy only matches
+	// during a window, and when its condition, if any, evaluates to true.
+	// +optional
+	Schedule *PolicySchedule `json:"schedule,omitempty"`
+
+	// The minimum time to stay in the policy once entered. Until it has elapsed,
+	// the policy is kept even if another policy matches.
+	// +optional
+	MinDwell *metav1.Duration `json:"minDwell,omitempty"`
+
+	// The time the condition must hold continuously before switching to the policy.
+	// It does not delay the first match of a target.
+	// +optional
+	EnterAfter *metav1.Duration `json:"enterAfter,omitempty"`
+}
+
+// PolicySchedule defines recurring time windows.
+type PolicySchedule struct {
+	// The start of the windows, as a standard cron expression with 5 fields,
+	// e.g. "0 2 * * 6" for every Saturday at 02:00.
+	Cron string `json:"cron"`
+
+	// The duration of every window.
+	Duration metav1.Duration `json:"duration"`
+
+	// The IANA time zone of the cron expression, e.g. "Europe/Berlin". Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 type VpaSpec struct {
@@ -56,6 +268,44 @@ type VpaSpec struct {
 	// recommendation) or contain exactly one recommender.
 	// +optional
 	Recommenders []*vpa.VerticalPodAutoscalerRecommenderSelector `json:"recommenders,omitempty" protobuf:"bytes,3,opt,name=recommenders"`
+
+	// Computes fields of the VerticalPodAutoscaler spec with expressions, written in the
+	// language of the policy and evaluated against the same variables as the condition.
+	// Computed fields override the corresponding static fields.
+	// +optional
+	Expressions *VpaSpecExpressions `json:"expressions,omitempty"`
+}
+
+// VpaSpecExpressions holds the expressions computing fields of a VerticalPodAutoscaler spec.
+type VpaSpecExpressions struct {
+	// Computes updatePolicy.updateMode. Must return "Off", "Initial", "Recreate" or "Auto".
+	// +optional
+	UpdateMode string `json:"updateMode,omitempty"`
+
+	// Computes fields of resourcePolicy.containerPolicies.
+	// +optional
+	ContainerPolicies []ContainerPolicyExpressions `json:"containerPolicies,omitempty"`
+}
+
+// ContainerPolicyExpressions holds the expressions computing fields of the resource policy of a container.
+type ContainerPolicyExpressions struct {
+	// The name of the container, or "*" for the default policy of all containers.
+	ContainerName string `json:"containerName"`
+
+	// Computes the minimal resources per resource name. Every expression must return
+	// a quantity, e.g. "100Mi", or a number in the base unit of the resource.
+	// +optional
+	MinAllowed map[corev1.ResourceName]string `json:"minAllowed,omitempty"`
+
+	// Computes the maximal resources per resource name. Every expression must return
+	// a quantity, e.g. "100Mi", or a number in the base unit of the resource.
+	// +optional
+	MaxAllowed map[corev1.ResourceName]string `json:"maxAllowed,omitempty"`
+
+	// Computes the resources for which recommendations are computed and applied.
+	// Must return a list of resource names.
+	// +optional
+	ControlledResources string `json:"controlledResources,omitempty"`
 }
 
 // DynamicVerticalPodAutoscalerStatus defines the observed state of DynamicVerticalPodAutoscaler
@@ -63,10 +313,222 @@ type DynamicVerticalPodAutoscalerStatus struct {
 	// The last time we updated the VerticalPodAutoscaler resource.
 	// +optional
 	VPALastUpdateTime metav1.Time `json:"vpaLastUpdateTime,omitempty"`
+
+	// The generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// The last time the policies were evaluated and changed the status.
+	// +optional
+	LastEvaluationTime metav1.Time `json:"lastEvaluationTime,omitempty"`
+
+	// The index of the policy that matched on the last evaluation.
+	// +optional
+	MatchedPolicyIndex *int32 `json:"matchedPolicyIndex,omitempty"`
+
+	// The name of the policy that matched on the last evaluation.
+	// +optional
+	MatchedPolicyName string `json:"matchedPolicyName,omitempty"`
+
+	// The noMatchAction applied on the last evaluation, when no policy matched.
+	// +optional
+	NoMatchAction NoMatchAction `json:"noMatchAction,omitempty"`
+
+	// The revision of the policy template evaluated on the last reconciliation.
+	// +optional
+	PolicyTemplate *PolicyTemplateStatus `json:"policyTemplate,omitempty"`
+
+	// The changes that would be made to the VerticalPodAutoscaler of the targetRef in DryRun mode.
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// The last transitions of the policy matched for the targetRef, oldest first.
+	// +optional
+	History []PolicyTransition `json:"history,omitempty"`
+
+	// The transition of the targetRef delayed by the minDwell or enterAfter of the policies.
+	// +optional
+	PendingTransition *PendingTransition `json:"pendingTransition,omitempty"`
+
+	// The status of every target selected by the targetSelector and managed by the controller,
+	// which manages a limited number of targets for every object.
+	// +optional
+	Targets []TargetStatus `json:"targets,omitempty"`
+
+	// Represents the observations of the DynamicVerticalPodAutoscaler's current state.
+	// Known condition types are "Ready", "PolicyMatched", "TargetFound", "VPASynced"
+	// and "ExpressionError".
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
+// PolicyTemplateStatus identifies the revision of a DynamicVerticalPodAutoscalerPolicyTemplate.
+type PolicyTemplateStatus struct {
+	Name string `json:"name"`
+
+	// The generation of the template.
+	Generation int64 `json:"generation"`
+}
+
+// TargetStatus is the status of a target selected by the targetSelector.
+type TargetStatus struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+
+	// The namespace of the target. Only set by ClusterDynamicVerticalPodAutoscalers.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// The name of the VerticalPodAutoscaler managed for the target.
+	// +optional
+	VPAName string `json:"vpaName,omitempty"`
+
+	// The index of the policy that matched on the last evaluation.
+	// +optional
+	MatchedPolicyIndex *int32 `json:"matchedPolicyIndex,omitempty"`
+
+	// The name of the policy that matched on the last evaluation.
+	// +optional
+	MatchedPolicyName string `json:"matchedPolicyName,omitempty"`
+
+	// The noMatchAction applied on the last evaluation, when no policy matched.
+	// +optional
+	NoMatchAction NoMatchAction `json:"noMatchAction,omitempty"`
+
+	// The error that occurred while reconciling the target, if any,
+	// or the reason why the target is not managed.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// The changes that would be made to the VerticalPodAutoscaler of the target in DryRun mode.
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// The last transitions of the policy matched for the target, oldest first.
+	// +optional
+	History []PolicyTransition `json:"history,omitempty"`
+
+	// The transition of the target delayed by the minDwell or enterAfter of the policies.
+	// +optional
+	PendingTransition *PendingTransition `json:"pendingTransition,omitempty"`
+}
+
+// PolicyTransition records a change of the matched policy.
+type PolicyTransition struct {
+	// The time of the transition.
+	Time metav1.Time `json:"time"`
+
+	// The index of the policy matched before the transition. Unset for the first match.
+	// +optional
+	From *int32 `json:"from,omitempty"`
+
+	// The index of the policy matched after the transition.
+	To int32 `json:"to"`
+
+	// A hash of the VerticalPodAutoscaler spec of the matched policy.
+	// Empty when the policy skips the reconciliation.
+	// +optional
+	SpecHash string `json:"specHash,omitempty"`
+}
+
+// PendingTransition is a change of policy delayed by the minDwell of the current policy
+// or the enterAfter of the matching policy.
+type PendingTransition struct {
+	// The index of the policy matching since the given time.
+	To int32 `json:"to"`
+
+	// The first evaluation on which the policy matched.
+	Since metav1.Time `json:"since"`
+
+	// The time at which the transition is made, if the policy still matches.
+	EligibleAt metav1.Time `json:"eligibleAt"`
+}
+
+// DryRunAction is the write to a VerticalPodAutoscaler skipped in DryRun mode.
+type DryRunAction string
+
+const (
+	DryRunActionCreate DryRunAction = "Create"
+	DryRunActionUpdate DryRunAction = "Update"
+	DryRunActionDelete DryRunAction = "Delete"
+	DryRunActionNone   DryRunAction = "None"
+)
+
+// DryRunStatus reports the changes that would be made to a VerticalPodAutoscaler.
+type DryRunStatus struct {
+	// Whether the VerticalPodAutoscaler would be created, updated or left unchanged.
+	Action DryRunAction `json:"action"`
+
+	// The spec the VerticalPodAutoscaler would have.
+	// +optional
+	VpaSpec *vpa.VerticalPodAutoscalerSpec `json:"vpaSpec,omitempty"`
+
+	// The fields of the spec of the live VerticalPodAutoscaler that would change,
+	// e.g. "updatePolicy.updateMode: Off -> Auto".
+	// +optional
+	Diff []string `json:"diff,omitempty"`
+}
+
+// Condition types reported in DynamicVerticalPodAutoscalerStatus.Conditions
+const (
+	// ConditionReady is True when the last reconciliation completed without error.
+	ConditionReady = "Ready"
+	// ConditionPolicyMatched is True when one of the policies matched.
+	ConditionPolicyMatched = "PolicyMatched"
+	// ConditionTargetFound is True when the targetRef object exists.
+	ConditionTargetFound = "TargetFound"
+	// ConditionVPASynced is True when the VerticalPodAutoscaler matches the wanted spec.
+	ConditionVPASynced = "VPASynced"
+	// ConditionExpressionError is True when a condition failed to compile or evaluate.
+	ConditionExpressionError = "ExpressionError"
+	// ConditionVPACRDMissing is True when the VerticalPodAutoscaler CRD is not installed.
+	ConditionVPACRDMissing = "VPACRDMissing"
+)
+
+// Condition reasons reported in DynamicVerticalPodAutoscalerStatus.Conditions
+const (
+	ReasonReconciled             = "Reconciled"
+	ReasonInvalidSpec            = "InvalidSpec"
+	ReasonMatched                = "Matched"
+	ReasonNoMatch                = "NoMatch"
+	ReasonTargetFound            = "TargetFound"
+	ReasonTargetNotFound         = "TargetNotFound"
+	ReasonCreated                = "Created"
+	ReasonUpdated                = "Updated"
+	ReasonUpToDate               = "UpToDate"
+	ReasonSyncFailed             = "SyncFailed"
+	ReasonNoError                = "NoError"
+	ReasonCompileError           = "CompileError"
+	ReasonRuntimeError           = "RuntimeError"
+	ReasonPolicySkipped          = "PolicySkipped"
+	ReasonReconcileFailed        = "ReconcileFailed"
+	ReasonManagedLocally         = "ManagedLocally"
+	ReasonPolicyTemplateNotFound = "PolicyTemplateNotFound"
+	ReasonDryRun                 = "DryRun"
+	ReasonTransitionPending      = "TransitionPending"
+	ReasonAdopted                = "Adopted"
+	ReasonAdoptionConflict       = "AdoptionConflict"
+	ReasonFieldConflict          = "FieldConflict"
+	ReasonVPACRDMissing          = "VPACRDMissing"
+	ReasonVPACRDInstalled        = "VPACRDInstalled"
+	ReasonKept                   = "Kept"
+	ReasonDeleted                = "Deleted"
+	ReasonTooManyTargets         = "TooManyTargets"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=dvpa
+//+kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.targetRef.name`
+//+kubebuilder:printcolumn:name="Policy",type=integer,JSONPath=`.status.matchedPolicyIndex`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // DynamicVerticalPodAutoscaler is the Schema for the dynamicverticalpodautoscalers API
 type DynamicVerticalPodAutoscaler struct {
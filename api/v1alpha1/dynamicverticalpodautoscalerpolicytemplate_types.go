@@ -0,0 +1,57 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DynamicVerticalPodAutoscalerPolicyTemplateSpec defines a reusable list of policies
+type DynamicVerticalPodAutoscalerPolicyTemplateSpec struct {
+	// +kubebuilder:validation:MinItems=1
+	Policies []DynamicVerticalPodAutoscalerPolicy `json:"policies"`
+
+	// The language of the policy conditions of the template.
+	// Defaults to the language of the referencing object.
+	// +optional
+	Language ConditionLanguage `json:"language,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=dvpapt
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DynamicVerticalPodAutoscalerPolicyTemplate is the Schema for the dynamicverticalpodautoscalerpolicytemplates API
+type DynamicVerticalPodAutoscalerPolicyTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DynamicVerticalPodAutoscalerPolicyTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DynamicVerticalPodAutoscalerPolicyTemplateList contains a list of DynamicVerticalPodAutoscalerPolicyTemplate
+type DynamicVerticalPodAutoscalerPolicyTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DynamicVerticalPodAutoscalerPolicyTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DynamicVerticalPodAutoscalerPolicyTemplate{}, &DynamicVerticalPodAutoscalerPolicyTemplateList{})
+}
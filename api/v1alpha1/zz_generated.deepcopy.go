@@ -21,11 +21,159 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/api/autoscaling/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	autoscaling_k8s_iov1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterDynamicVerticalPodAutoscaler) DeepCopyInto(out *ClusterDynamicVerticalPodAutoscaler) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterDynamicVerticalPodAutoscaler.
+func (in *ClusterDynamicVerticalPodAutoscaler) DeepCopy() *ClusterDynamicVerticalPodAutoscaler {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterDynamicVerticalPodAutoscaler)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterDynamicVerticalPodAutoscaler) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterDynamicVerticalPodAutoscalerList) DeepCopyInto(out *ClusterDynamicVerticalPodAutoscalerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterDynamicVerticalPodAutoscaler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterDynamicVerticalPodAutoscalerList.
+func (in *ClusterDynamicVerticalPodAutoscalerList) DeepCopy() *ClusterDynamicVerticalPodAutoscalerList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterDynamicVerticalPodAutoscalerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterDynamicVerticalPodAutoscalerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterDynamicVerticalPodAutoscalerSpec) DeepCopyInto(out *ClusterDynamicVerticalPodAutoscalerSpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.TargetSelector.DeepCopyInto(&out.TargetSelector)
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]DynamicVerticalPodAutoscalerPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultVpaSpec != nil {
+		in, out := &in.DefaultVpaSpec, &out.DefaultVpaSpec
+		*out = new(VpaSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterDynamicVerticalPodAutoscalerSpec.
+func (in *ClusterDynamicVerticalPodAutoscalerSpec) DeepCopy() *ClusterDynamicVerticalPodAutoscalerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterDynamicVerticalPodAutoscalerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerPolicyExpressions) DeepCopyInto(out *ContainerPolicyExpressions) {
+	*out = *in
+	if in.MinAllowed != nil {
+		in, out := &in.MinAllowed, &out.MinAllowed
+		*out = make(map[corev1.ResourceName]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MaxAllowed != nil {
+		in, out := &in.MaxAllowed, &out.MaxAllowed
+		*out = make(map[corev1.ResourceName]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerPolicyExpressions.
+func (in *ContainerPolicyExpressions) DeepCopy() *ContainerPolicyExpressions {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerPolicyExpressions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunStatus) DeepCopyInto(out *DryRunStatus) {
+	*out = *in
+	if in.VpaSpec != nil {
+		in, out := &in.VpaSpec, &out.VpaSpec
+		*out = new(autoscaling_k8s_iov1.VerticalPodAutoscalerSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Diff != nil {
+		in, out := &in.Diff, &out.Diff
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunStatus.
+func (in *DryRunStatus) DeepCopy() *DryRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DynamicVerticalPodAutoscaler) DeepCopyInto(out *DynamicVerticalPodAutoscaler) {
 	*out = *in
@@ -89,6 +237,21 @@ func (in *DynamicVerticalPodAutoscalerList) DeepCopyObject() runtime.Object {
 func (in *DynamicVerticalPodAutoscalerPolicy) DeepCopyInto(out *DynamicVerticalPodAutoscalerPolicy) {
 	*out = *in
 	in.VpaSpec.DeepCopyInto(&out.VpaSpec)
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(PolicySchedule)
+		**out = **in
+	}
+	if in.MinDwell != nil {
+		in, out := &in.MinDwell, &out.MinDwell
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.EnterAfter != nil {
+		in, out := &in.EnterAfter, &out.EnterAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicVerticalPodAutoscalerPolicy.
@@ -101,14 +264,99 @@ func (in *DynamicVerticalPodAutoscalerPolicy) DeepCopy() *DynamicVerticalPodAuto
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplate) DeepCopyInto(out *DynamicVerticalPodAutoscalerPolicyTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicVerticalPodAutoscalerPolicyTemplate.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplate) DeepCopy() *DynamicVerticalPodAutoscalerPolicyTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicVerticalPodAutoscalerPolicyTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplateList) DeepCopyInto(out *DynamicVerticalPodAutoscalerPolicyTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DynamicVerticalPodAutoscalerPolicyTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicVerticalPodAutoscalerPolicyTemplateList.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplateList) DeepCopy() *DynamicVerticalPodAutoscalerPolicyTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicVerticalPodAutoscalerPolicyTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplateSpec) DeepCopyInto(out *DynamicVerticalPodAutoscalerPolicyTemplateSpec) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]DynamicVerticalPodAutoscalerPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicVerticalPodAutoscalerPolicyTemplateSpec.
+func (in *DynamicVerticalPodAutoscalerPolicyTemplateSpec) DeepCopy() *DynamicVerticalPodAutoscalerPolicyTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicVerticalPodAutoscalerPolicyTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DynamicVerticalPodAutoscalerSpec) DeepCopyInto(out *DynamicVerticalPodAutoscalerSpec) {
 	*out = *in
 	if in.TargetRef != nil {
 		in, out := &in.TargetRef, &out.TargetRef
-		*out = new(v1.CrossVersionObjectReference)
+		*out = new(autoscalingv1.CrossVersionObjectReference)
 		**out = **in
 	}
+	if in.TargetSelector != nil {
+		in, out := &in.TargetSelector, &out.TargetSelector
+		*out = new(TargetSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Policies != nil {
 		in, out := &in.Policies, &out.Policies
 		*out = make([]DynamicVerticalPodAutoscalerPolicy, len(*in))
@@ -116,6 +364,16 @@ func (in *DynamicVerticalPodAutoscalerSpec) DeepCopyInto(out *DynamicVerticalPod
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PolicyTemplateRef != nil {
+		in, out := &in.PolicyTemplateRef, &out.PolicyTemplateRef
+		*out = new(PolicyTemplateReference)
+		**out = **in
+	}
+	if in.DefaultVpaSpec != nil {
+		in, out := &in.DefaultVpaSpec, &out.DefaultVpaSpec
+		*out = new(VpaSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicVerticalPodAutoscalerSpec.
@@ -132,6 +390,48 @@ func (in *DynamicVerticalPodAutoscalerSpec) DeepCopy() *DynamicVerticalPodAutosc
 func (in *DynamicVerticalPodAutoscalerStatus) DeepCopyInto(out *DynamicVerticalPodAutoscalerStatus) {
 	*out = *in
 	in.VPALastUpdateTime.DeepCopyInto(&out.VPALastUpdateTime)
+	in.LastEvaluationTime.DeepCopyInto(&out.LastEvaluationTime)
+	if in.MatchedPolicyIndex != nil {
+		in, out := &in.MatchedPolicyIndex, &out.MatchedPolicyIndex
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PolicyTemplate != nil {
+		in, out := &in.PolicyTemplate, &out.PolicyTemplate
+		*out = new(PolicyTemplateStatus)
+		**out = **in
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]PolicyTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PendingTransition != nil {
+		in, out := &in.PendingTransition, &out.PendingTransition
+		*out = new(PendingTransition)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicVerticalPodAutoscalerStatus.
@@ -144,6 +444,167 @@ func (in *DynamicVerticalPodAutoscalerStatus) DeepCopy() *DynamicVerticalPodAuto
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingTransition) DeepCopyInto(out *PendingTransition) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	in.EligibleAt.DeepCopyInto(&out.EligibleAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingTransition.
+func (in *PendingTransition) DeepCopy() *PendingTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicySchedule) DeepCopyInto(out *PolicySchedule) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicySchedule.
+func (in *PolicySchedule) DeepCopy() *PolicySchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicySchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTemplateReference) DeepCopyInto(out *PolicyTemplateReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTemplateReference.
+func (in *PolicyTemplateReference) DeepCopy() *PolicyTemplateReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTemplateReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTemplateStatus) DeepCopyInto(out *PolicyTemplateStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTemplateStatus.
+func (in *PolicyTemplateStatus) DeepCopy() *PolicyTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTransition) DeepCopyInto(out *PolicyTransition) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTransition.
+func (in *PolicyTransition) DeepCopy() *PolicyTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetKind) DeepCopyInto(out *TargetKind) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetKind.
+func (in *TargetKind) DeepCopy() *TargetKind {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetKind)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetSelector) DeepCopyInto(out *TargetSelector) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]TargetKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetSelector.
+func (in *TargetSelector) DeepCopy() *TargetSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetStatus) DeepCopyInto(out *TargetStatus) {
+	*out = *in
+	if in.MatchedPolicyIndex != nil {
+		in, out := &in.MatchedPolicyIndex, &out.MatchedPolicyIndex
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]PolicyTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PendingTransition != nil {
+		in, out := &in.PendingTransition, &out.PendingTransition
+		*out = new(PendingTransition)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetStatus.
+func (in *TargetStatus) DeepCopy() *TargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VpaSpec) DeepCopyInto(out *VpaSpec) {
 	*out = *in
@@ -168,6 +629,11 @@ func (in *VpaSpec) DeepCopyInto(out *VpaSpec) {
 			}
 		}
 	}
+	if in.Expressions != nil {
+		in, out := &in.Expressions, &out.Expressions
+		*out = new(VpaSpecExpressions)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VpaSpec.
@@ -179,3 +645,25 @@ func (in *VpaSpec) DeepCopy() *VpaSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VpaSpecExpressions) DeepCopyInto(out *VpaSpecExpressions) {
+	*out = *in
+	if in.ContainerPolicies != nil {
+		in, out := &in.ContainerPolicies, &out.ContainerPolicies
+		*out = make([]ContainerPolicyExpressions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VpaSpecExpressions.
+func (in *VpaSpecExpressions) DeepCopy() *VpaSpecExpressions {
+	if in == nil {
+		return nil
+	}
+	out := new(VpaSpecExpressions)
+	in.DeepCopyInto(out)
+	return out
+}
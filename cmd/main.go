@@ -17,11 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	"k8s.io/client-go/scale/scheme/autoscalingv1"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -31,6 +33,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -60,6 +63,12 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var resyncPeriod time.Duration
+	var programCacheSize int
+	var historySize int
+	var maxTargets int
+	var dryRun bool
+	var allowedKinds string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -69,6 +78,24 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&resyncPeriod, "resync-period", controller.DefaultResyncPeriod,
+		"The interval at which policies are re-evaluated in the absence of changes. "+
+			"Time-based conditions are only re-evaluated at this interval.")
+	flag.IntVar(&programCacheSize, "program-cache-size", controller.DefaultProgramCacheSize,
+		"The maximum number of compiled policy conditions kept in memory.")
+	flag.IntVar(&historySize, "history-size", controller.DefaultHistorySize,
+		"The maximum number of policy transitions kept in the status of every target.")
+	flag.IntVar(&maxTargets, "max-targets", controller.DefaultMaxTargets,
+		"The maximum number of workloads selected by the targetSelector of an object which are managed "+
+			"and listed in its status.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, policies are evaluated but no VerticalPodAutoscaler is written, "+
+			"as if every object had mode DryRun.")
+	flag.StringVar(&allowedKinds, "allowed-kinds", "",
+		"A comma-separated list of the kinds of workloads which can be targeted, written Kind.group, "+
+			"such as Rollout.argoproj.io. The list replaces the default, so the built-in kinds must be listed "+
+			"to remain allowed. The kinds which are not built-in must be served with a scale subresource. "+
+			"Defaults to the built-in kinds supported by the VerticalPodAutoscaler.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -93,12 +120,23 @@ func main() {
 		tlsOpts = append(tlsOpts, disableHTTP2)
 	}
 
+	workloadKinds, err := controller.ParseAllowedKinds(allowedKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid allowed kinds")
+		os.Exit(1)
+	}
+
 	webhookServer := webhook.NewServer(webhook.Options{
 		TLSOpts: tlsOpts,
 	})
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		// The targets are read as unstructured objects. Serve them from
+		// the cache populated by the target watches.
+		Client: client.Options{
+			Cache: &client.CacheOptions{Unstructured: true},
+		},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -126,13 +164,68 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := controller.CheckAllowedKinds(mgr, workloadKinds); err != nil {
+		setupLog.Error(err, "invalid allowed kinds")
+		os.Exit(1)
+	}
+
+	// The indexes and the summary of the cluster are shared by the reconcilers.
+	if err := controller.SetupPodIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up the pod indexes")
+		os.Exit(1)
+	}
+	clusterFacts, err := controller.NewClusterFacts(context.Background(), mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to set up the cluster facts")
+		os.Exit(1)
+	}
+	vpaCRD := controller.NewVPACRD(mgr)
 	if err = (&controller.DynamicVerticalPodAutoscalerReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		ResyncPeriod:     resyncPeriod,
+		ProgramCacheSize: programCacheSize,
+		HistorySize:      historySize,
+		MaxTargets:       maxTargets,
+		DryRun:           dryRun,
+		AllowedKinds:     workloadKinds,
+		VPACRD:           vpaCRD,
+		PodsIndexed:      true,
+		ClusterFacts:     clusterFacts,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DynamicVerticalPodAutoscaler")
 		os.Exit(1)
 	}
+	if err = (&controller.ClusterDynamicVerticalPodAutoscalerReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		ResyncPeriod:     resyncPeriod,
+		ProgramCacheSize: programCacheSize,
+		HistorySize:      historySize,
+		MaxTargets:       maxTargets,
+		DryRun:           dryRun,
+		AllowedKinds:     workloadKinds,
+		VPACRD:           vpaCRD,
+		PodsIndexed:      true,
+		ClusterFacts:     clusterFacts,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterDynamicVerticalPodAutoscaler")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&controller.DynamicVerticalPodAutoscalerValidator{AllowedKinds: workloadKinds}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "DynamicVerticalPodAutoscaler")
+			os.Exit(1)
+		}
+		if err = (&controller.ClusterDynamicVerticalPodAutoscalerValidator{AllowedKinds: workloadKinds}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterDynamicVerticalPodAutoscaler")
+			os.Exit(1)
+		}
+		if err = (&controller.DynamicVerticalPodAutoscalerPolicyTemplateValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "DynamicVerticalPodAutoscalerPolicyTemplate")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -143,6 +236,10 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("vpa-crd", vpaCRD.Check); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
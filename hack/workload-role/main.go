@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// workload-role generates the ClusterRole and ClusterRoleBinding granting the controller access to the
+// allowed kinds of workloads which are not built-in. The built-in kinds are granted by the RBAC markers
+// of the controllers.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/internal/controller"
+)
+
+func main() {
+	var allowedKinds string
+	flag.StringVar(&allowedKinds, "allowed-kinds", "",
+		"The comma-separated list of the allowed kinds, as passed to the --allowed-kinds flag of the controller.")
+	flag.Parse()
+
+	if err := run(allowedKinds); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(allowedKinds string) error {
+	kinds, err := controller.ParseAllowedKinds(allowedKinds)
+	if err != nil {
+		return err
+	}
+	builtin := make(map[schema.GroupKind]bool, len(controller.DefaultAllowedKinds))
+	for _, kind := range controller.DefaultAllowedKinds {
+		builtin[kind] = true
+	}
+
+	labels := func(name, instance string) map[string]string {
+		return map[string]string{
+			"app.kubernetes.io/name":       name,
+			"app.kubernetes.io/instance":   instance,
+			"app.kubernetes.io/component":  "rbac",
+			"app.kubernetes.io/created-by": "dynamic-vertical-pod-autoscaler",
+			"app.kubernetes.io/part-of":    "dynamic-vertical-pod-autoscaler",
+			"app.kubernetes.io/managed-by": "kustomize",
+		}
+	}
+
+	role := rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: "workload-role", Labels: labels("clusterrole", "workload-role")},
+		Rules:      []rbacv1.PolicyRule{},
+	}
+	for _, kind := range kinds {
+		if builtin[kind] {
+			continue
+		}
+		// The resource is guessed as the default RESTMapper does for the kinds it does not know.
+		resource, _ := meta.UnsafeGuessKindToResource(kind.WithVersion(""))
+		role.Rules = append(role.Rules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{kind.Group},
+				Resources: []string{resource.Resource},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{kind.Group},
+				Resources: []string{resource.Resource + "/scale"},
+				Verbs:     []string{"get"},
+			},
+		)
+	}
+
+	binding := rbacv1.ClusterRoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{Name: "workload-rolebinding", Labels: labels("clusterrolebinding", "workload-rolebinding")},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "workload-role"},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      "controller-manager",
+			Namespace: "system",
+		}},
+	}
+
+	fmt.Println("# Code generated by hack/workload-role from ALLOWED_KINDS. DO NOT EDIT.")
+	for i, obj := range []interface{}{role, binding} {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(out))
+	}
+	return nil
+}
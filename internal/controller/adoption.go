@@ -0,0 +1,79 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// adoptionConflict returns why the existing VerticalPodAutoscaler existingVpa cannot be managed for src
+// under its adoption policy, or an empty string if it is controlled by src or can be adopted.
+func adoptionConflict(src *policySource, existingVpa *vpa.VerticalPodAutoscaler) string {
+	if metav1.IsControlledBy(existingVpa, src.obj) {
+		return ""
+	}
+	owner := metav1.GetControllerOf(existingVpa)
+	switch src.adoptionPolicy {
+	case v1alpha1.AdoptionPolicyAlways:
+		return ""
+	case v1alpha1.AdoptionPolicyIfUnowned:
+		if owner == nil {
+			return ""
+		}
+	}
+	if owner != nil {
+		return fmt.Sprintf("VerticalPodAutoscaler %s/%s is controlled by %s %q and the adoptionPolicy is %s",
+			existingVpa.Namespace, existingVpa.Name, owner.Kind, owner.Name, adoptionPolicyOrDefault(src.adoptionPolicy))
+	}
+	return fmt.Sprintf("VerticalPodAutoscaler %s/%s already exists and the adoptionPolicy is %s",
+		existingVpa.Namespace, existingVpa.Name, adoptionPolicyOrDefault(src.adoptionPolicy))
+}
+
+// adoptionPolicyOrDefault returns the adoption policy, defaulted to Never.
+func adoptionPolicyOrDefault(policy v1alpha1.AdoptionPolicy) v1alpha1.AdoptionPolicy {
+	if len(policy) == 0 {
+		return v1alpha1.AdoptionPolicyNever
+	}
+	return policy
+}
+
+// adopt makes src the controller of existingVpa, replacing its previous controller if any,
+// and labels it so that it is deleted once its target is no longer selected.
+func adopt(src *policySource, existingVpa *vpa.VerticalPodAutoscaler, scheme *runtime.Scheme) error {
+	var refs []metav1.OwnerReference
+	for _, ref := range existingVpa.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller || ref.UID == src.obj.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+	existingVpa.OwnerReferences = refs
+	if err := controllerutil.SetControllerReference(src.obj, existingVpa, scheme); err != nil {
+		return err
+	}
+	if existingVpa.Labels == nil {
+		existingVpa.Labels = map[string]string{}
+	}
+	existingVpa.Labels[src.labelKey] = src.obj.GetName()
+	return nil
+}
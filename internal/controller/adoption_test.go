@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Adoption", func() {
+	var src *policySource
+
+	BeforeEach(func() {
+		src = namespacedPolicySource(&v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default", UID: "dvpa"},
+		})
+	})
+
+	// existing returns a VerticalPodAutoscaler controlled by the object with the given UID, if any.
+	existing := func(ownerUID types.UID) *vpa.VerticalPodAutoscaler {
+		obj := &vpa.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+		if len(ownerUID) > 0 {
+			obj.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: v1alpha1.GroupVersion.String(),
+				Kind:       "DynamicVerticalPodAutoscaler",
+				Name:       string(ownerUID),
+				UID:        ownerUID,
+				Controller: ptr.To(true),
+			}}
+		}
+		return obj
+	}
+
+	DescribeTable("should report conflicts according to the adoptionPolicy",
+		func(policy v1alpha1.AdoptionPolicy, ownerUID string, conflict bool) {
+			src.adoptionPolicy = policy
+			if conflict {
+				Expect(adoptionConflict(src, existing(types.UID(ownerUID)))).NotTo(BeEmpty())
+			} else {
+				Expect(adoptionConflict(src, existing(types.UID(ownerUID)))).To(BeEmpty())
+			}
+		},
+		Entry("own VPA", v1alpha1.AdoptionPolicyNever, "dvpa", false),
+		Entry("unowned VPA by default", v1alpha1.AdoptionPolicy(""), "", true),
+		Entry("unowned VPA with Never", v1alpha1.AdoptionPolicyNever, "", true),
+		Entry("unowned VPA with IfUnowned", v1alpha1.AdoptionPolicyIfUnowned, "", false),
+		Entry("controlled VPA with IfUnowned", v1alpha1.AdoptionPolicyIfUnowned, "other", true),
+		Entry("controlled VPA with Always", v1alpha1.AdoptionPolicyAlways, "other", false),
+	)
+
+	It("should replace the controller and label the VPA on adoption", func() {
+		obj := existing("other")
+		obj.OwnerReferences = append(obj.OwnerReferences, metav1.OwnerReference{
+			APIVersion: "v1", Kind: "ConfigMap", Name: "config", UID: "config",
+		})
+		scheme := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(adopt(src, obj, scheme)).To(Succeed())
+
+		Expect(metav1.IsControlledBy(obj, src.obj)).To(BeTrue())
+		Expect(obj.OwnerReferences).To(HaveLen(2))
+		Expect(obj.Labels).To(HaveKeyWithValue(v1alpha1.DynamicVerticalPodAutoscalerLabel, "example"))
+	})
+})
+
+var _ = Describe("Server-side apply", func() {
+	It("should only apply the fields set by the policy", func() {
+		src := namespacedPolicySource(&v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default", UID: "dvpa"},
+		})
+		scheme := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		updateMode := vpa.UpdateModeAuto
+		applied, err := vpaApplyConfiguration(src, client.ObjectKey{Namespace: "default", Name: "example"},
+			vpa.VerticalPodAutoscalerSpec{UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateMode}}, scheme)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(applied.GetAPIVersion()).To(Equal("autoscaling.k8s.io/v1"))
+		Expect(applied.GetKind()).To(Equal("VerticalPodAutoscaler"))
+		Expect(applied.GetLabels()).To(HaveKeyWithValue(v1alpha1.DynamicVerticalPodAutoscalerLabel, "example"))
+		Expect(metav1.IsControlledBy(applied, src.obj)).To(BeTrue())
+		Expect(applied.Object).NotTo(HaveKey("status"))
+		Expect(applied.Object["metadata"]).NotTo(HaveKey("creationTimestamp"))
+		Expect(applied.Object["spec"]).To(Equal(map[string]interface{}{
+			"targetRef":    nil,
+			"updatePolicy": map[string]interface{}{"updateMode": "Auto"},
+		}))
+	})
+
+	It("should detect the objects applied by a manager", func() {
+		obj := &vpa.VerticalPodAutoscaler{}
+		Expect(appliedBy(obj, fieldManager)).To(BeFalse())
+
+		obj.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate}}
+		Expect(appliedBy(obj, fieldManager)).To(BeFalse())
+
+		obj.ManagedFields = append(obj.ManagedFields, metav1.ManagedFieldsEntry{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply})
+		Expect(appliedBy(obj, fieldManager)).To(BeTrue())
+	})
+})
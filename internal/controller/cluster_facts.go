@@ -0,0 +1,160 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// clusterVersionRefreshPeriod is the interval at which the version of the server is read again,
+// so that the cluster variable reflects the upgrades of the control plane.
+const clusterVersionRefreshPeriod = 10 * time.Minute
+
+// ClusterFacts summarises the nodes and the version of the cluster for the cluster variable.
+// The summary is computed from the cache on first use, and again after the nodes changed or
+// the version of the server, read every clusterVersionRefreshPeriod, changed.
+type ClusterFacts struct {
+	reader        client.Reader
+	serverVersion func() (*version.Info, error)
+
+	mu          sync.Mutex
+	stale       bool
+	value       map[string]interface{}
+	version     *version.Info
+	versionTime time.Time
+}
+
+// NewClusterFacts returns the ClusterFacts of mgr, whose nodes are watched through the cache of mgr.
+// They are shared by the reconcilers of mgr.
+func NewClusterFacts(ctx context.Context, mgr ctrl.Manager) (*ClusterFacts, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	facts := &ClusterFacts{reader: mgr.GetClient(), serverVersion: discoveryClient.ServerVersion, stale: true}
+
+	informer, err := mgr.GetCache().GetInformer(ctx, &corev1.Node{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { facts.invalidate() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// Nodes are updated on every heartbeat, only their allocatable resources matter.
+			oldNode, _ := oldObj.(*corev1.Node)
+			newNode, _ := newObj.(*corev1.Node)
+			if oldNode == nil || newNode == nil || !reflect.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable) {
+				facts.invalidate()
+			}
+		},
+		DeleteFunc: func(interface{}) { facts.invalidate() },
+	}); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// invalidate marks the summary as stale, so that it is computed again on next use.
+func (f *ClusterFacts) invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stale = true
+}
+
+// get returns the value of the cluster variable.
+func (f *ClusterFacts) get(ctx context.Context) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.refreshVersion(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+	if !f.stale {
+		return f.value, nil
+	}
+
+	var nodes corev1.NodeList
+	if err := f.reader.List(ctx, &nodes); err != nil {
+		return nil, err
+	}
+	f.value = summarizeCluster(nodes.Items, f.version)
+	f.stale = false
+	return f.value, nil
+}
+
+// refreshVersion reads the version of the server when it was last read more than
+// clusterVersionRefreshPeriod before now, and marks the summary as stale when it changed.
+// The last version is kept when it cannot be read. f.mu must be held.
+func (f *ClusterFacts) refreshVersion(ctx context.Context, now time.Time) error {
+	if f.version != nil && now.Sub(f.versionTime) < clusterVersionRefreshPeriod {
+		return nil
+	}
+	serverVersion, err := f.serverVersion()
+	if err != nil {
+		if f.version == nil {
+			return err
+		}
+		log.FromContext(ctx).Error(err, "Unable to refresh the version of the server")
+		return nil
+	}
+	if !reflect.DeepEqual(serverVersion, f.version) {
+		f.version = serverVersion
+		f.stale = true
+	}
+	f.versionTime = now
+	return nil
+}
+
+// summarizeCluster returns the value of the cluster variable: the number of nodes, the sum of
+// their allocatable resources as quantities, and the version of the server.
+func summarizeCluster(nodes []corev1.Node, serverVersion *version.Info) map[string]interface{} {
+	totals := corev1.ResourceList{}
+	for i := range nodes {
+		for name, quantity := range nodes[i].Status.Allocatable {
+			total := totals[name]
+			total.Add(quantity)
+			totals[name] = total
+		}
+	}
+	allocatable := make(map[string]interface{}, len(totals))
+	for name, quantity := range totals {
+		allocatable[string(name)] = quantity.String()
+	}
+
+	versionValue := map[string]interface{}{}
+	if serverVersion != nil {
+		versionValue["major"] = serverVersion.Major
+		versionValue["minor"] = serverVersion.Minor
+		versionValue["gitVersion"] = serverVersion.GitVersion
+	}
+
+	return map[string]interface{}{
+		"nodeCount":   len(nodes),
+		"allocatable": allocatable,
+		"version":     versionValue,
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/version"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Cluster facts", func() {
+	node := func(cpu, memory string) corev1.Node {
+		return corev1.Node{Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}}}
+	}
+	cluster := summarizeCluster(
+		[]corev1.Node{node("3500m", "16Gi"), node("4", "32Gi")},
+		&version.Info{Major: "1", Minor: "28", GitVersion: "v1.28.3"},
+	)
+
+	It("should sum the allocatable resources of the nodes", func() {
+		Expect(cluster["nodeCount"]).To(Equal(2))
+		Expect(cluster["allocatable"]).To(Equal(map[string]interface{}{"cpu": "7500m", "memory": "48Gi"}))
+	})
+
+	DescribeTable("should expose the namespace and the cluster to the conditions",
+		func(language v1alpha1.ConditionLanguage, condition string) {
+			env := programEnv(nil, nil, nil)
+			p, err := compileCondition(language, condition, env)
+			Expect(err).NotTo(HaveOccurred())
+
+			env["namespace"] = map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":   "shop",
+					"labels": map[string]interface{}{"environment": "prod"},
+				},
+			}
+			env["cluster"] = cluster
+			matched, err := p.run(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+		},
+		Entry("expr", v1alpha1.ConditionLanguageExpr,
+			`namespace.metadata.labels?.environment == "prod" && cluster.nodeCount > 1 && cluster.version.minor == "28"`),
+		Entry("cel", v1alpha1.ConditionLanguageCEL,
+			`ns.metadata.labels.environment == "prod" && cluster.nodeCount > 1 && cluster.allocatable.memory == "48Gi"`),
+	)
+
+	Describe("the version of the server", func() {
+		ctx := context.Background()
+		now := time.Now()
+
+		var (
+			facts         *ClusterFacts
+			serverVersion *version.Info
+			versionErr    error
+			reads         int
+		)
+		BeforeEach(func() {
+			serverVersion, versionErr, reads = &version.Info{Major: "1", Minor: "28"}, nil, 0
+			facts = &ClusterFacts{serverVersion: func() (*version.Info, error) {
+				reads++
+				return serverVersion, versionErr
+			}}
+		})
+
+		It("should be read again after the refresh period", func() {
+			Expect(facts.refreshVersion(ctx, now)).To(Succeed())
+			Expect(facts.version.Minor).To(Equal("28"))
+			Expect(facts.stale).To(BeTrue())
+
+			facts.stale = false
+			Expect(facts.refreshVersion(ctx, now.Add(clusterVersionRefreshPeriod/2))).To(Succeed())
+			Expect(reads).To(Equal(1))
+
+			serverVersion = &version.Info{Major: "1", Minor: "29"}
+			Expect(facts.refreshVersion(ctx, now.Add(clusterVersionRefreshPeriod))).To(Succeed())
+			Expect(reads).To(Equal(2))
+			Expect(facts.version.Minor).To(Equal("29"))
+			Expect(facts.stale).To(BeTrue())
+		})
+
+		It("should keep the last version when it cannot be read", func() {
+			versionErr = errors.New("unavailable")
+			Expect(facts.refreshVersion(ctx, now)).NotTo(Succeed())
+
+			versionErr = nil
+			Expect(facts.refreshVersion(ctx, now)).To(Succeed())
+
+			serverVersion, versionErr = nil, errors.New("unavailable")
+			Expect(facts.refreshVersion(ctx, now.Add(clusterVersionRefreshPeriod))).To(Succeed())
+			Expect(facts.version.Minor).To(Equal("28"))
+		})
+	})
+})
@@ -0,0 +1,421 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// ClusterDynamicVerticalPodAutoscalerReconciler reconciles a ClusterDynamicVerticalPodAutoscaler object
+type ClusterDynamicVerticalPodAutoscalerReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder emits the events of the reconciled objects and their targets.
+	// SetupWithManager defaults it to a recorder of the manager. No event is emitted when nil.
+	Recorder record.EventRecorder
+
+	// ResyncPeriod is the interval at which the policies are re-evaluated
+	// in the absence of any change to the object, its targets or their VPAs.
+	// Defaults to DefaultResyncPeriod.
+	ResyncPeriod time.Duration
+
+	// ProgramCacheSize is the maximum number of compiled conditions kept in memory.
+	// Defaults to DefaultProgramCacheSize.
+	ProgramCacheSize int
+
+	// HistorySize is the maximum number of policy transitions kept in the status of every target.
+	// Defaults to DefaultHistorySize.
+	HistorySize int
+
+	// MaxTargets is the maximum number of targets of a targetSelector which are managed and listed
+	// in the status of every object, so that the status stays within the size limit of the objects.
+	// Defaults to DefaultMaxTargets.
+	MaxTargets int
+
+	// DryRun evaluates the policies of every object without writing any VerticalPodAutoscaler,
+	// as if their mode was DryRun.
+	DryRun bool
+
+	// AllowedKinds are the kinds of workloads which can be targeted. The kinds which are not built-in
+	// must have a scale subresource. Defaults to DefaultAllowedKinds.
+	AllowedKinds []schema.GroupKind
+
+	// VPACRD detects the VerticalPodAutoscaler CRD and starts the watch of the VerticalPodAutoscalers
+	// once it is installed. SetupWithManager defaults it to a VPACRD of the manager.
+	// The CRD is looked up with the RESTMapper of the client when nil.
+	VPACRD *VPACRD
+
+	// PodsIndexed is true when the indexes of SetupPodIndexes are registered on the manager,
+	// so that the Pods of the targets are listed in the pods variable. It is empty otherwise.
+	PodsIndexed bool
+
+	// ClusterFacts summarises the cluster for the cluster variable. It is shared by the reconcilers
+	// of a manager, and SetupWithManager defaults it to a ClusterFacts of the manager.
+	// The cluster variable is nil when the reconciler is used without a manager.
+	ClusterFacts *ClusterFacts
+
+	programsOnce sync.Once
+	programs     *programCache
+
+	targetWatcher *targetWatcher
+}
+
+//+kubebuilder:rbac:groups=autoscaling.stackrox.io,resources=clusterdynamicverticalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling.stackrox.io,resources=clusterdynamicverticalpodautoscalers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=autoscaling.stackrox.io,resources=clusterdynamicverticalpodautoscalers/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var obj v1alpha1.ClusterDynamicVerticalPodAutoscaler
+	if err := r.Get(ctx, req.NamespacedName, &obj); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			deleteObjectMetrics(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !obj.DeletionTimestamp.IsZero() {
+		src := clusterPolicySource(&obj)
+		src.dryRun = src.dryRun || r.DryRun
+		return ctrl.Result{}, r.targets().finalize(ctx, src)
+	}
+
+	original := obj.Status.DeepCopy()
+
+	// The VerticalPodAutoscaler CRD may be installed after the controller is started.
+	installed, err := r.VPACRD.installed(ctx, r.RESTMapper())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !installed {
+		logger.Info("The VerticalPodAutoscaler CRD is not installed, waiting for it")
+		recordEvent(r.Recorder, &obj, corev1.EventTypeWarning, reasonVPACRDNotFound, errVPACRDMissing.Error())
+		trackSteps(&obj.Status, obj.Generation)(withReason(v1alpha1.ReasonVPACRDMissing, errVPACRDMissing))
+		vpaCRDStatus(&obj.Status, obj.Generation, installed)
+		// The CRD is looked up again at the next resync, without backing off.
+		return resyncResult(r.ResyncPeriod), updateStatus(ctx, r.Client, &obj, original, &obj.Status)
+	}
+
+	// The finalizer is added before the status is computed, since the update returns the stored status.
+	// A failure is reported in the status like the failures of the reconciliation.
+	err = ensureFinalizer(ctx, r.Client, &obj)
+	vpaCRDStatus(&obj.Status, obj.Generation, installed)
+
+	steps := trackSteps(&obj.Status, obj.Generation)
+	var result ctrl.Result
+	if err == nil {
+		result, err = r.reconcile(ctx, &obj)
+	}
+	steps(err)
+
+	readyStatus(&obj.Status, obj.Generation, err)
+	if statusErr := updateStatus(ctx, r.Client, &obj, original, &obj.Status); statusErr != nil {
+		return ctrl.Result{}, errors.Join(err, statusErr)
+	}
+
+	return result, err
+}
+
+// reconcile evaluates the policies of obj for every selected workload and synchronises their VerticalPodAutoscalers.
+// Workloads targeted by a DynamicVerticalPodAutoscaler of their namespace are left to it.
+// The status of obj is updated in place and persisted by the caller.
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) reconcile(ctx context.Context, obj *v1alpha1.ClusterDynamicVerticalPodAutoscaler) (ctrl.Result, error) {
+	if errs := validateClusterSpec(obj, newWorkloadKinds(r.AllowedKinds)); len(errs) > 0 {
+		return ctrl.Result{}, withReason(v1alpha1.ReasonInvalidSpec, errs.ToAggregate())
+	}
+
+	namespaces, err := r.listSelectedNamespaces(ctx, obj)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(obj.Spec.TargetSelector.Selector)
+	if err != nil {
+		return ctrl.Result{}, withReason(v1alpha1.ReasonInvalidSpec, err)
+	}
+	candidates, err := listTargets(ctx, r.Client, r.targetWatcher, obj.Spec.TargetSelector.Kinds, "", selector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var locals v1alpha1.DynamicVerticalPodAutoscalerList
+	if err := r.List(ctx, &locals); err != nil {
+		return ctrl.Result{}, err
+	}
+	localsByNamespace := map[string][]v1alpha1.DynamicVerticalPodAutoscaler{}
+	for _, local := range locals.Items {
+		localsByNamespace[local.Namespace] = append(localsByNamespace[local.Namespace], local)
+	}
+
+	var (
+		selected   []unstructured.Unstructured
+		overridden []v1alpha1.TargetStatus
+	)
+	for _, target := range candidates {
+		if !namespaces.Has(target.GetNamespace()) {
+			continue
+		}
+		if owner := localOwner(localsByNamespace[target.GetNamespace()], &target); len(owner) > 0 {
+			overridden = append(overridden, v1alpha1.TargetStatus{
+				APIVersion: target.GetAPIVersion(),
+				Kind:       target.GetKind(),
+				Name:       target.GetName(),
+				Namespace:  target.GetNamespace(),
+				Message:    fmt.Sprintf("managed by DynamicVerticalPodAutoscaler %q", owner),
+			})
+			continue
+		}
+		selected = append(selected, target)
+	}
+
+	src := clusterPolicySource(obj)
+	src.dryRun = src.dryRun || r.DryRun
+	targets := r.targets()
+	wanted, boundary, err := targets.reconcileSelectedTargets(ctx, src, &obj.Status, selected)
+	// The workloads managed locally are only listed in the room left by the managed ones, so that the
+	// status stays within the size limit of the object.
+	if room := targets.maxTargetsOrDefault() - len(obj.Status.Targets); len(overridden) > room {
+		sort.Slice(overridden, func(i, j int) bool {
+			return targetStatusKeyOf(&overridden[i]).less(targetStatusKeyOf(&overridden[j]))
+		})
+		obj.Status.Targets = append(obj.Status.Targets, overridden[:max(room, 0)]...)
+	} else {
+		obj.Status.Targets = append(obj.Status.Targets, overridden...)
+	}
+	if len(overridden) > 0 && len(selected) == 0 {
+		setStatusCondition(&obj.Status, obj.Generation, v1alpha1.ConditionTargetFound, metav1.ConditionFalse, v1alpha1.ReasonManagedLocally,
+			fmt.Sprintf("all %d matching workloads are managed by a DynamicVerticalPodAutoscaler", len(overridden)))
+	}
+	if staleErr := targets.deleteStaleVPAs(ctx, src, wanted); staleErr != nil {
+		err = errors.Join(err, staleErr)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	now := time.Now()
+	return boundaryResult(pendingResult(resyncResult(r.ResyncPeriod), &obj.Status, now), boundary, now), nil
+}
+
+// listSelectedNamespaces returns the names of the namespaces matching the namespaceSelector of obj.
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) listSelectedNamespaces(
+	ctx context.Context,
+	obj *v1alpha1.ClusterDynamicVerticalPodAutoscaler,
+) (sets.Set[string], error) {
+	selector := labels.Everything()
+	if obj.Spec.NamespaceSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(obj.Spec.NamespaceSelector); err != nil {
+			return nil, withReason(v1alpha1.ReasonInvalidSpec, err)
+		}
+	}
+
+	var list corev1.NamespaceList
+	if err := r.List(ctx, &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	namespaces := sets.New[string]()
+	for _, ns := range list.Items {
+		namespaces.Insert(ns.Name)
+	}
+	return namespaces, nil
+}
+
+// localOwner returns the name of the DynamicVerticalPodAutoscaler among locals that targets the given workload,
+// or an empty string if there is none. Namespace-local objects take precedence over cluster-wide ones.
+func localOwner(locals []v1alpha1.DynamicVerticalPodAutoscaler, target *unstructured.Unstructured) string {
+	gk := target.GroupVersionKind().GroupKind()
+	for _, local := range locals {
+		switch {
+		case local.Spec.TargetRef != nil:
+			gv, err := schema.ParseGroupVersion(local.Spec.TargetRef.APIVersion)
+			if err == nil && gv.WithKind(local.Spec.TargetRef.Kind).GroupKind() == gk && local.Spec.TargetRef.Name == target.GetName() {
+				return local.Name
+			}
+		case local.Spec.TargetSelector != nil:
+			if !selectsKind(local.Spec.TargetSelector, gk) {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(local.Spec.TargetSelector.Selector)
+			if err == nil && selector.Matches(labels.Set(target.GetLabels())) {
+				return local.Name
+			}
+		}
+	}
+	return ""
+}
+
+// selectsKind returns true if the kinds of selector include gk.
+func selectsKind(selector *v1alpha1.TargetSelector, gk schema.GroupKind) bool {
+	for _, kind := range selector.Kinds {
+		gv, err := schema.ParseGroupVersion(kind.APIVersion)
+		if err == nil && gv.WithKind(kind.Kind).GroupKind() == gk {
+			return true
+		}
+	}
+	return false
+}
+
+// validateClusterSpec performs the sanity checks that do not require any lookup.
+func validateClusterSpec(obj *v1alpha1.ClusterDynamicVerticalPodAutoscaler, kinds workloadKinds) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if obj.Spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(obj.Spec.NamespaceSelector); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("namespaceSelector"), obj.Spec.NamespaceSelector, err.Error()))
+		}
+	}
+	errs = append(errs, validateTargetSelector(&obj.Spec.TargetSelector, kinds, specPath.Child("targetSelector"))...)
+	errs = append(errs, validateVPANameTemplate(obj.Spec.VPANameTemplate, specPath.Child("vpaNameTemplate"))...)
+	errs = append(errs, validateNoMatchAction(obj.Spec.NoMatchAction, obj.Spec.DefaultVpaSpec, specPath)...)
+
+	if len(obj.Spec.Policies) == 0 {
+		errs = append(errs, field.Required(specPath.Child("policies"), "at least one policy is required"))
+	}
+	return errs
+}
+
+// programCache returns the cache of compiled conditions, creating it on first use.
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) programCache() *programCache {
+	r.programsOnce.Do(func() {
+		r.programs = newProgramCache(r.ProgramCacheSize)
+	})
+	return r.programs
+}
+
+// targets returns the targetReconciler evaluating the policies of the ClusterDynamicVerticalPodAutoscalers.
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) targets() *targetReconciler {
+	return &targetReconciler{
+		Client:      r.Client,
+		scheme:      r.Scheme,
+		recorder:    r.Recorder,
+		programs:    r.programCache(),
+		historySize: r.HistorySize,
+		maxTargets:  r.MaxTargets,
+		podsIndexed: r.PodsIndexed,
+		cluster:     r.ClusterFacts,
+		workloads:   newWorkloadKinds(r.AllowedKinds),
+	}
+}
+
+// findObjectsForTarget maps a target to the ClusterDynamicVerticalPodAutoscalers selecting it.
+// The namespace of the target is checked on reconciliation.
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) findObjectsForTarget(ctx context.Context, target client.Object) []reconcile.Request {
+	gk := target.GetObjectKind().GroupVersionKind().GroupKind()
+
+	var list v1alpha1.ClusterDynamicVerticalPodAutoscalerList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list ClusterDynamicVerticalPodAutoscalers for target",
+			"kind", gk, "namespace", target.GetNamespace(), "name", target.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, item := range list.Items {
+		if !selectsKind(&item.Spec.TargetSelector, gk) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(item.Spec.TargetSelector.Selector)
+		if err != nil || !selector.Matches(labels.Set(target.GetLabels())) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	return requests
+}
+
+// findAllObjects maps any object to all the ClusterDynamicVerticalPodAutoscalers.
+// It is used for the namespaces and the DynamicVerticalPodAutoscalers, which can change the selected workloads.
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) findAllObjects(ctx context.Context, _ client.Object) []reconcile.Request {
+	var list v1alpha1.ClusterDynamicVerticalPodAutoscalerList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list ClusterDynamicVerticalPodAutoscalers")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// The targets are watched dynamically, as their kinds are only known once the objects are reconciled.
+func (r *ClusterDynamicVerticalPodAutoscalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor(eventRecorderName)
+	}
+	if r.VPACRD == nil {
+		r.VPACRD = NewVPACRD(mgr)
+	}
+	if r.ClusterFacts == nil {
+		clusterFacts, err := NewClusterFacts(context.Background(), mgr)
+		if err != nil {
+			return err
+		}
+		r.ClusterFacts = clusterFacts
+	}
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		// Status updates do not bump the generation, so they do not trigger a new reconciliation.
+		// Deletions may not bump the generation either, and must remove the finalizer.
+		For(&v1alpha1.ClusterDynamicVerticalPodAutoscaler{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, deletionPredicate))).
+		Watches(&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.findAllObjects),
+			builder.WithPredicates(namespacePredicate)).
+		Watches(&v1alpha1.DynamicVerticalPodAutoscaler{},
+			handler.EnqueueRequestsFromMapFunc(r.findAllObjects),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// The VerticalPodAutoscalers are not watched with Owns, as the controller would fail to start
+	// without their CRD.
+	r.VPACRD.watch(c, &v1alpha1.ClusterDynamicVerticalPodAutoscaler{})
+	r.targetWatcher = newTargetWatcher(mgr, c, handler.EnqueueRequestsFromMapFunc(r.findObjectsForTarget))
+	return nil
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("ClusterDynamicVerticalPodAutoscaler Controller", func() {
+	const resourceName = "test-cluster"
+
+	ctx := context.Background()
+	typeNamespacedName := types.NamespacedName{Name: resourceName}
+
+	newNamespace := func(name string, labels map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	newDeployment := func(namespace, name string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{"team": "cluster"},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": name},
+				},
+				Template: v1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{Name: name, Image: "nginx"}},
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		By("creating the namespaces and their deployments")
+		// The namespaces are never deleted by envtest, which runs no namespace controller.
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, newNamespace(resourceName+"-tenant", map[string]string{"tenant": "true"})))).To(Succeed())
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, newNamespace(resourceName+"-system", nil)))).To(Succeed())
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, newDeployment(resourceName+"-tenant", "app")))).To(Succeed())
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, newDeployment(resourceName+"-tenant", "local")))).To(Succeed())
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, newDeployment(resourceName+"-system", "app")))).To(Succeed())
+
+		By("creating a DynamicVerticalPodAutoscaler taking precedence")
+		local := &v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: resourceName + "-tenant"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetRef: &autoscaling.CrossVersionObjectReference{
+					APIVersion: "apps/v1",
+					Kind:       "Deployment",
+					Name:       "local",
+				},
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{}},
+			},
+		}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, local))).To(Succeed())
+
+		resource := &v1alpha1.ClusterDynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: resourceName},
+			Spec: v1alpha1.ClusterDynamicVerticalPodAutoscalerSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}},
+				TargetSelector: v1alpha1.TargetSelector{
+					Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "cluster"}},
+				},
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{
+					VpaSpec: v1alpha1.VpaSpec{
+						UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff},
+					},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		resource := &v1alpha1.ClusterDynamicVerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		_, err := (&ClusterDynamicVerticalPodAutoscalerReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}).
+			Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should manage the workloads of the selected namespaces", func() {
+		controllerReconciler := &ClusterDynamicVerticalPodAutoscalerReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+		}
+		reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+		_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating a VerticalPodAutoscaler for the deployment of the selected namespace")
+		created := &vpa.VerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{
+			Name: resourceName + "-deployment-app", Namespace: resourceName + "-tenant"}, created)).To(Succeed())
+		Expect(created.Labels).To(HaveKeyWithValue(v1alpha1.ClusterDynamicVerticalPodAutoscalerLabel, resourceName))
+
+		By("ignoring the deployments of other namespaces")
+		err = k8sClient.Get(ctx, types.NamespacedName{
+			Name: resourceName + "-deployment-app", Namespace: resourceName + "-system"}, &vpa.VerticalPodAutoscaler{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		By("leaving the deployments targeted by a DynamicVerticalPodAutoscaler")
+		err = k8sClient.Get(ctx, types.NamespacedName{
+			Name: resourceName + "-deployment-local", Namespace: resourceName + "-tenant"}, &vpa.VerticalPodAutoscaler{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		resource := &v1alpha1.ClusterDynamicVerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(resource.Status.Targets).To(HaveLen(2))
+		Expect(resource.Status.Targets[1].Name).To(Equal("local"))
+		Expect(resource.Status.Targets[1].VPAName).To(BeEmpty())
+	})
+
+	It("should only list the workloads managed locally up to the maximum number of targets", func() {
+		controllerReconciler := &ClusterDynamicVerticalPodAutoscalerReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			MaxTargets: 1,
+		}
+		reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+		By("creating a DynamicVerticalPodAutoscaler selecting every deployment of the namespace")
+		selecting := &v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "selecting", Namespace: resourceName + "-tenant"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetSelector: &v1alpha1.TargetSelector{
+					Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "cluster"}},
+				},
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, selecting)).To(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(ctx, selecting)).To(Succeed())
+		}()
+
+		_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+		Expect(err).NotTo(HaveOccurred())
+
+		resource := &v1alpha1.ClusterDynamicVerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(resource.Status.Targets).To(HaveLen(1))
+		Expect(resource.Status.Targets[0].Name).To(Equal("app"))
+		targetFound := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionTargetFound)
+		Expect(targetFound.Reason).To(Equal(v1alpha1.ReasonManagedLocally))
+		Expect(targetFound.Message).To(ContainSubstring("all 2 matching workloads"))
+	})
+})
+
+var _ = Describe("localOwner", func() {
+	target := &unstructured.Unstructured{}
+	target.SetAPIVersion("apps/v1")
+	target.SetKind("Deployment")
+	target.SetName("example")
+	target.SetLabels(map[string]string{"team": "a"})
+
+	It("should find the DynamicVerticalPodAutoscaler referencing the target", func() {
+		locals := []v1alpha1.DynamicVerticalPodAutoscaler{{
+			ObjectMeta: metav1.ObjectMeta{Name: "ref"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetRef: &autoscaling.CrossVersionObjectReference{APIVersion: "apps/v1beta1", Kind: "Deployment", Name: "example"},
+			},
+		}}
+		Expect(localOwner(locals, target)).To(Equal("ref"))
+	})
+
+	It("should find the DynamicVerticalPodAutoscaler selecting the target", func() {
+		locals := []v1alpha1.DynamicVerticalPodAutoscaler{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-kind"},
+				Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+					TargetSelector: &v1alpha1.TargetSelector{
+						Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "StatefulSet"}},
+						Selector: &metav1.LabelSelector{},
+					},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "selector"},
+				Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+					TargetSelector: &v1alpha1.TargetSelector{
+						Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+					},
+				},
+			},
+		}
+		Expect(localOwner(locals, target)).To(Equal("selector"))
+		Expect(localOwner(locals[:1], target)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-autoscaling-stackrox-io-v1alpha1-clusterdynamicverticalpodautoscaler,mutating=false,failurePolicy=fail,sideEffects=None,groups=autoscaling.stackrox.io,resources=clusterdynamicverticalpodautoscalers,verbs=create;update,versions=v1alpha1,name=vclusterdynamicverticalpodautoscaler.kb.io,admissionReviewVersions=v1
+
+// ClusterDynamicVerticalPodAutoscalerValidator validates ClusterDynamicVerticalPodAutoscaler objects
+// before they are stored, with the checks of the DynamicVerticalPodAutoscalerValidator.
+type ClusterDynamicVerticalPodAutoscalerValidator struct {
+	// AllowedKinds are the kinds of workloads which can be targeted, as in the reconcilers.
+	// Defaults to DefaultAllowedKinds.
+	AllowedKinds []schema.GroupKind
+}
+
+var _ webhook.CustomValidator = &ClusterDynamicVerticalPodAutoscalerValidator{}
+
+// SetupWebhookWithManager registers the validating webhook with the Manager.
+func (v *ClusterDynamicVerticalPodAutoscalerValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.ClusterDynamicVerticalPodAutoscaler{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *ClusterDynamicVerticalPodAutoscalerValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator
+func (v *ClusterDynamicVerticalPodAutoscalerValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldTyped, oldOK := oldObj.(*v1alpha1.ClusterDynamicVerticalPodAutoscaler)
+	newTyped, newOK := newObj.(*v1alpha1.ClusterDynamicVerticalPodAutoscaler)
+	if oldOK && newOK && !updateValidated(newTyped, oldTyped.Spec, newTyped.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *ClusterDynamicVerticalPodAutoscalerValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *ClusterDynamicVerticalPodAutoscalerValidator) validate(o runtime.Object) error {
+	obj, ok := o.(*v1alpha1.ClusterDynamicVerticalPodAutoscaler)
+	if !ok {
+		return fmt.Errorf("expected a ClusterDynamicVerticalPodAutoscaler but got %T", o)
+	}
+
+	errs := validateClusterSpec(obj, newWorkloadKinds(v.AllowedKinds))
+	errs = append(errs, validatePolicies(obj.Spec.Policies, obj.Spec.Language)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("ClusterDynamicVerticalPodAutoscaler").GroupKind(), obj.Name, errs)
+}
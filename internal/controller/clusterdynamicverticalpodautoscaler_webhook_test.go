@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("ClusterDynamicVerticalPodAutoscaler Webhook", func() {
+	ctx := context.Background()
+	validator := &ClusterDynamicVerticalPodAutoscalerValidator{}
+
+	var obj *v1alpha1.ClusterDynamicVerticalPodAutoscaler
+
+	BeforeEach(func() {
+		obj = &v1alpha1.ClusterDynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			Spec: v1alpha1.ClusterDynamicVerticalPodAutoscalerSpec{
+				TargetSelector: v1alpha1.TargetSelector{
+					Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+				},
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{
+					{Condition: `target.metadata.annotations?.["vpa-disabled"] == "true" ?? false`},
+					{},
+				},
+			},
+		}
+	})
+
+	// causes returns the field paths of the causes of a validation error.
+	causes := func(err error) []string {
+		statusErr, ok := err.(*apierrors.StatusError)
+		Expect(ok).To(BeTrue())
+		var fields []string
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		return fields
+	}
+
+	It("should accept a valid object", func() {
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject a kind that is not allowed", func() {
+		obj.Spec.TargetSelector.Kinds[0].Kind = "Rollout"
+		obj.Spec.TargetSelector.Kinds[0].APIVersion = "argoproj.io/v1alpha1"
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetSelector.kinds[0].kind"))
+	})
+
+	It("should reject an empty list of policies", func() {
+		obj.Spec.Policies = nil
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies"))
+	})
+
+	It("should reject conditions that do not compile", func() {
+		oldObj := obj.DeepCopy()
+		obj.Spec.Policies[0].Condition = "target.metadata.name =="
+		_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[0].condition"))
+	})
+
+	It("should reject a Default noMatchAction without defaultVpaSpec", func() {
+		obj.Spec.NoMatchAction = v1alpha1.NoMatchActionDefault
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.defaultVpaSpec"))
+	})
+
+	It("should only validate the updates of the spec of objects not being deleted", func() {
+		obj.Spec.TargetSelector.Kinds[0].Kind = "Rollout"
+		obj.Spec.TargetSelector.Kinds[0].APIVersion = "argoproj.io/v1alpha1"
+		oldObj := obj.DeepCopy()
+
+		obj.Finalizers = []string{v1alpha1.Finalizer}
+		_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		obj.DeletionTimestamp = ptr.To(metav1.Now())
+		obj.Spec.NoMatchAction = v1alpha1.NoMatchActionKeep
+		_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		obj.DeletionTimestamp = nil
+		_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetSelector.kinds[0].kind"))
+	})
+})
@@ -0,0 +1,171 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// dryRunVPA returns the changes that syncVPA would make to the VerticalPodAutoscaler vpaKey so that the
+// fields of wantVpaSpec are applied. They are computed with a server-side dry-run apply, so that the fields
+// owned by other managers and the defaults set by the API server are not reported as changes.
+func (t *targetReconciler) dryRunVPA(
+	ctx context.Context,
+	src *policySource,
+	vpaKey client.ObjectKey,
+	foundVPA *vpa.VerticalPodAutoscaler,
+	vpaExists bool,
+	wantVpaSpec vpa.VerticalPodAutoscalerSpec,
+) (*v1alpha1.DryRunStatus, error) {
+	if !vpaExists {
+		return dryRunStatus(foundVPA, false, wantVpaSpec, wantVpaSpec), nil
+	}
+
+	applied, err := vpaApplyConfiguration(src, vpaKey, wantVpaSpec, t.scheme)
+	if err != nil {
+		return nil, err
+	}
+	// The controller of an adopted VerticalPodAutoscaler is only replaced by syncVPA before the apply,
+	// and the owner references do not change the spec.
+	unstructured.RemoveNestedField(applied.Object, "metadata", "ownerReferences")
+	// The fields written with updates by previous versions of the controller are only moved to its
+	// fieldManager by syncVPA, and the fields of an adopted VerticalPodAutoscaler are taken over by its
+	// first apply, so the controller then owns the fields of the apply.
+	takeOver := !metav1.IsControlledBy(foundVPA, src.obj) || !appliedBy(foundVPA, fieldManager)
+	opts := append(applyOptions(src, takeOver), client.DryRunAll)
+	if err := t.Patch(ctx, applied, client.Apply, opts...); err != nil {
+		return nil, err
+	}
+
+	result := &vpa.VerticalPodAutoscaler{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(applied.Object, result); err != nil {
+		return nil, err
+	}
+	return dryRunStatus(foundVPA, true, wantVpaSpec, result.Spec), nil
+}
+
+// dryRunStatus returns the changes between the spec of foundVPA and appliedSpec, the spec it would have
+// once wantVpaSpec is applied.
+func dryRunStatus(
+	foundVPA *vpa.VerticalPodAutoscaler,
+	vpaExists bool,
+	wantVpaSpec, appliedSpec vpa.VerticalPodAutoscalerSpec,
+) *v1alpha1.DryRunStatus {
+	status := &v1alpha1.DryRunStatus{VpaSpec: wantVpaSpec.DeepCopy()}
+	switch {
+	case !vpaExists:
+		status.Action = v1alpha1.DryRunActionCreate
+	case equality.Semantic.DeepEqual(foundVPA.Spec, appliedSpec):
+		status.Action = v1alpha1.DryRunActionNone
+	default:
+		status.Action = v1alpha1.DryRunActionUpdate
+		status.Diff = diffVpaSpec(&foundVPA.Spec, &appliedSpec)
+	}
+	return status
+}
+
+// recordDryRun emits an event on the owner of the VerticalPodAutoscaler vpaKey describing the skipped write.
+// matchedIndex is -1 when no policy matched.
+func (t *targetReconciler) recordDryRun(src *policySource, vpaKey client.ObjectKey, matchedIndex int, status *v1alpha1.DryRunStatus) {
+	matched := "no policy matched"
+	if matchedIndex >= 0 {
+		matched = fmt.Sprintf("policy %s matched", policyDisplayName(matchedIndex, &src.policies[matchedIndex]))
+	}
+	message := fmt.Sprintf("%s, would %s VerticalPodAutoscaler %s", matched, strings.ToLower(string(status.Action)), vpaKey)
+	if len(status.Diff) > 0 {
+		message += ": " + strings.Join(status.Diff, ", ")
+	}
+	t.event(src.obj, corev1.EventTypeNormal, v1alpha1.ReasonDryRun, message)
+}
+
+// diffVpaSpec returns the fields that differ between two VerticalPodAutoscaler specs,
+// as "path: old -> new", sorted by path. Lists are compared as a whole.
+func diffVpaSpec(live, want *vpa.VerticalPodAutoscalerSpec) []string {
+	liveFields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	wantFields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(want)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var diff []string
+	diffFields("", liveFields, wantFields, &diff)
+	return diff
+}
+
+// diffFields appends the differences between two unstructured values at path to diff.
+func diffFields(path string, live, want interface{}, diff *[]string) {
+	liveMap, liveIsMap := live.(map[string]interface{})
+	wantMap, wantIsMap := want.(map[string]interface{})
+	if !liveIsMap || !wantIsMap {
+		if !reflect.DeepEqual(live, want) {
+			*diff = append(*diff, fmt.Sprintf("%s: %s -> %s", path, formatField(live), formatField(want)))
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(liveMap)+len(wantMap))
+	for key := range liveMap {
+		keys = append(keys, key)
+	}
+	for key := range wantMap {
+		if _, ok := liveMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldPath := key
+		if len(path) > 0 {
+			fieldPath = path + "." + key
+		}
+		diffFields(fieldPath, liveMap[key], wantMap[key], diff)
+	}
+}
+
+// formatField returns a compact representation of an unstructured value.
+func formatField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "<unset>"
+	case string:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
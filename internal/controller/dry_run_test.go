@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("dryRunStatus", func() {
+	updateModeAuto := vpa.UpdateModeAuto
+	live := &vpa.VerticalPodAutoscaler{
+		Spec: vpa.VerticalPodAutoscalerSpec{
+			TargetRef:    &autoscaling.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "example"},
+			UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff},
+		},
+	}
+
+	It("should report a missing VerticalPodAutoscaler as created", func() {
+		status := dryRunStatus(&vpa.VerticalPodAutoscaler{}, false, live.Spec, live.Spec)
+		Expect(status.Action).To(Equal(v1alpha1.DryRunActionCreate))
+		Expect(*status.VpaSpec).To(Equal(live.Spec))
+		Expect(status.Diff).To(BeEmpty())
+	})
+
+	It("should report an up-to-date VerticalPodAutoscaler as unchanged", func() {
+		status := dryRunStatus(live, true, live.Spec, *live.Spec.DeepCopy())
+		Expect(status.Action).To(Equal(v1alpha1.DryRunActionNone))
+		Expect(status.Diff).To(BeEmpty())
+	})
+
+	It("should not report the fields kept by the apply as changed", func() {
+		// The resourcePolicy set by another manager is kept when the policy does not set it.
+		applied := live.Spec.DeepCopy()
+		applied.ResourcePolicy = &vpa.PodResourcePolicy{
+			ContainerPolicies: []vpa.ContainerResourcePolicy{{ContainerName: "app"}},
+		}
+		withPolicy := live.DeepCopy()
+		withPolicy.Spec.ResourcePolicy = applied.ResourcePolicy.DeepCopy()
+
+		status := dryRunStatus(withPolicy, true, live.Spec, *applied)
+		Expect(status.Action).To(Equal(v1alpha1.DryRunActionNone))
+		Expect(*status.VpaSpec).To(Equal(live.Spec))
+		Expect(status.Diff).To(BeEmpty())
+	})
+
+	It("should report the changed fields of the live VerticalPodAutoscaler", func() {
+		want := live.Spec.DeepCopy()
+		want.UpdatePolicy.UpdateMode = &updateModeAuto
+		want.ResourcePolicy = &vpa.PodResourcePolicy{
+			ContainerPolicies: []vpa.ContainerResourcePolicy{{
+				ContainerName: "app",
+				MaxAllowed:    corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			}},
+		}
+
+		status := dryRunStatus(live, true, *want, *want)
+		Expect(status.Action).To(Equal(v1alpha1.DryRunActionUpdate))
+		Expect(status.Diff).To(Equal([]string{
+			`resourcePolicy: <unset> -> {"containerPolicies":[{"containerName":"app","maxAllowed":{"memory":"1Gi"}}]}`,
+			"updatePolicy.updateMode: Off -> Auto",
+		}))
+	})
+})
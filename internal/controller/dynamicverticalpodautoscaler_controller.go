@@ -19,19 +19,27 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
-	"reflect"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/expr-lang/expr"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
 )
@@ -40,212 +48,371 @@ import (
 type DynamicVerticalPodAutoscalerReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Recorder emits the events of the reconciled objects and their targets.
+	// SetupWithManager defaults it to a recorder of the manager. No event is emitted when nil.
+	Recorder record.EventRecorder
+
+	// ResyncPeriod is the interval at which the policies are re-evaluated
+	// in the absence of any change to the object, its target or its VPA.
+	// Defaults to DefaultResyncPeriod.
+	ResyncPeriod time.Duration
+
+	// ProgramCacheSize is the maximum number of compiled conditions kept in memory.
+	// Defaults to DefaultProgramCacheSize.
+	ProgramCacheSize int
+
+	// HistorySize is the maximum number of policy transitions kept in the status of every target.
+	// Defaults to DefaultHistorySize.
+	HistorySize int
+
+	// MaxTargets is the maximum number of targets of a targetSelector which are managed and listed
+	// in the status of every object, so that the status stays within the size limit of the objects.
+	// Defaults to DefaultMaxTargets.
+	MaxTargets int
+
+	// DryRun evaluates the policies of every object without writing any VerticalPodAutoscaler,
+	// as if their mode was DryRun.
+	DryRun bool
+
+	// AllowedKinds are the kinds of workloads which can be targeted. The kinds which are not built-in
+	// must have a scale subresource. Defaults to DefaultAllowedKinds.
+	AllowedKinds []schema.GroupKind
+
+	// VPACRD detects the VerticalPodAutoscaler CRD and starts the watch of the VerticalPodAutoscalers
+	// once it is installed. SetupWithManager defaults it to a VPACRD of the manager.
+	// The CRD is looked up with the RESTMapper of the client when nil.
+	VPACRD *VPACRD
+
+	// PodsIndexed is true when the indexes of SetupPodIndexes are registered on the manager,
+	// so that the Pods of the targets are listed in the pods variable. It is empty otherwise.
+	PodsIndexed bool
+
+	// ClusterFacts summarises the cluster for the cluster variable. It is shared by the reconcilers
+	// of a manager, and SetupWithManager defaults it to a ClusterFacts of the manager.
+	// The cluster variable is nil when the reconciler is used without a manager.
+	ClusterFacts *ClusterFacts
+
+	programsOnce sync.Once
+	programs     *programCache
+
+	targetWatcher *targetWatcher
+}
+
+// DefaultResyncPeriod is the default ResyncPeriod.
+// Time-based conditions, such as the ones using now(), are re-evaluated at this interval.
+const DefaultResyncPeriod = time.Minute
+
+// defaultResult returns the result used to schedule the safety-net resync.
+func (r *DynamicVerticalPodAutoscalerReconciler) defaultResult() ctrl.Result {
+	return resyncResult(r.ResyncPeriod)
 }
 
-// defaultResult sets the default RequeueAfter.
-var defaultResult = ctrl.Result{Requeue: true, RequeueAfter: time.Second * 10}
+// resyncResult returns the result used to schedule the safety-net resync after period.
+func resyncResult(period time.Duration) ctrl.Result {
+	if period <= 0 {
+		return ctrl.Result{RequeueAfter: DefaultResyncPeriod}
+	}
+	return ctrl.Result{RequeueAfter: period}
+}
 
 //+kubebuilder:rbac:groups=autoscaling.stackrox.io,resources=dynamicverticalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=autoscaling.stackrox.io,resources=dynamicverticalpodautoscalers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=autoscaling.stackrox.io,resources=dynamicverticalpodautoscalers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=autoscaling.stackrox.io,resources=dynamicverticalpodautoscalerpolicytemplates,verbs=get;list;watch
 //+kubebuilder:rbac:groups=autoscaling.k8s.io,resources=verticalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
 //+kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=replicationcontrollers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 
 func (r *DynamicVerticalPodAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
-	// Ensure that the VerticalPodAutoscaler CRD is installed
-	if _, err := r.RESTMapper().KindFor(vpa.SchemeGroupVersion.WithResource("verticalpodautoscalers")); err != nil {
-		logger.Error(err, "The VerticalPodAutoscaler CRD is not installed. Please install it before using this controller.")
-		return ctrl.Result{}, err
-	}
-
 	var obj v1alpha1.DynamicVerticalPodAutoscaler
 	if err := r.Get(ctx, req.NamespacedName, &obj); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			deleteObjectMetrics(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// Sanity checks
-	if obj.Spec.TargetRef == nil {
-		return ctrl.Result{}, errors.New("targetRef is required")
-	}
-	if len(obj.Spec.TargetRef.Kind) == 0 {
-		return ctrl.Result{}, errors.New("targetRef.kind is required")
+	if !obj.DeletionTimestamp.IsZero() {
+		src := namespacedPolicySource(&obj)
+		src.dryRun = src.dryRun || r.DryRun
+		return ctrl.Result{}, r.targets().finalize(ctx, src)
 	}
-	if len(obj.Spec.TargetRef.APIVersion) == 0 {
-		return ctrl.Result{}, errors.New("targetRef.apiVersion is required")
+
+	original := obj.Status.DeepCopy()
+
+	// The VerticalPodAutoscaler CRD may be installed after the controller is started.
+	installed, err := r.VPACRD.installed(ctx, r.RESTMapper())
+	if err != nil {
+		return ctrl.Result{}, err
 	}
-	if len(obj.Spec.TargetRef.Name) == 0 {
-		return ctrl.Result{}, errors.New("targetRef.name is required")
+	if !installed {
+		logger.Info("The VerticalPodAutoscaler CRD is not installed, waiting for it")
+		recordEvent(r.Recorder, &obj, corev1.EventTypeWarning, reasonVPACRDNotFound, errVPACRDMissing.Error())
+		trackSteps(&obj.Status, obj.Generation)(withReason(v1alpha1.ReasonVPACRDMissing, errVPACRDMissing))
+		vpaCRDStatus(&obj.Status, obj.Generation, installed)
+		// The CRD is looked up again at the next resync, without backing off.
+		return r.defaultResult(), updateStatus(ctx, r.Client, &obj, original, &obj.Status)
 	}
-	if len(obj.Spec.Policies) == 0 {
-		return ctrl.Result{}, errors.New("conditions is required")
+
+	// The finalizer is added before the status is computed, since the update returns the stored status.
+	// A failure is reported in the status like the failures of the reconciliation.
+	err = ensureFinalizer(ctx, r.Client, &obj)
+	vpaCRDStatus(&obj.Status, obj.Generation, installed)
+
+	steps := trackSteps(&obj.Status, obj.Generation)
+	var result ctrl.Result
+	if err == nil {
+		result, err = r.reconcile(ctx, &obj)
 	}
+	steps(err)
 
-	vpaTarget, err := r.getVPATarget(ctx, obj)
-	if client.IgnoreNotFound(err) != nil {
-		return ctrl.Result{}, err
+	// Always report the outcome of the reconciliation in the status,
+	// so that failures are visible without reading the controller logs.
+	readyStatus(&obj.Status, obj.Generation, err)
+	if statusErr := updateStatus(ctx, r.Client, &obj, original, &obj.Status); statusErr != nil {
+		return ctrl.Result{}, errors.Join(err, statusErr)
 	}
 
-	var existingVpa = &vpa.VerticalPodAutoscaler{}
-	if err := r.Get(ctx, client.ObjectKey{Name: obj.Name, Namespace: obj.Namespace}, existingVpa); err != nil {
-		if client.IgnoreNotFound(err) != nil {
-			return ctrl.Result{}, err
-		}
+	return result, err
+}
+
+// reconcile evaluates the policies of obj and synchronises the VerticalPodAutoscalers.
+// The status of obj is updated in place and persisted by the caller.
+func (r *DynamicVerticalPodAutoscalerReconciler) reconcile(ctx context.Context, obj *v1alpha1.DynamicVerticalPodAutoscaler) (ctrl.Result, error) {
+	// Sanity checks
+	if errs := validateSpec(obj, newWorkloadKinds(r.AllowedKinds)); len(errs) > 0 {
+		return ctrl.Result{}, withReason(v1alpha1.ReasonInvalidSpec, errs.ToAggregate())
 	}
 
-	env, err := r.getProgramEnv(obj, existingVpa, vpaTarget)
+	src, err := r.resolvePolicySource(ctx, obj)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	var matchedPolicy *v1alpha1.DynamicVerticalPodAutoscalerPolicy
-	for i, policy := range obj.Spec.Policies {
-
-		logger.V(5).Info("Checking policy",
-			"condition", policy.Condition,
-			"index", i,
-		)
+	if obj.Spec.TargetSelector != nil {
+		return r.reconcileTargetSelector(ctx, obj, src)
+	}
+	return r.reconcileTargetRef(ctx, obj, src)
+}
 
-		if len(policy.Condition) == 0 {
-			// When condition is empty, this evaluates to true
-			matchedPolicy = &policy
-			break
-		}
+// reconcileTargetRef reconciles the VerticalPodAutoscaler of the single target referenced by targetRef.
+func (r *DynamicVerticalPodAutoscalerReconciler) reconcileTargetRef(
+	ctx context.Context,
+	obj *v1alpha1.DynamicVerticalPodAutoscaler,
+	src *policySource,
+) (ctrl.Result, error) {
+	obj.Status.Targets = nil
 
-		program, err := expr.Compile(policy.Condition, expr.Env(env))
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+	vpaTarget, err := r.getVPATarget(ctx, *obj)
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.ensureTargetWatch(ctx, targetGVK(obj)); err != nil {
+		return ctrl.Result{}, err
+	}
+	if vpaTarget == nil {
+		message := fmt.Sprintf("%s %q not found", obj.Spec.TargetRef.Kind, obj.Spec.TargetRef.Name)
+		recordEvent(r.Recorder, obj, corev1.EventTypeWarning, v1alpha1.ReasonTargetNotFound, message)
+		setCondition(obj, v1alpha1.ConditionTargetFound, metav1.ConditionFalse, v1alpha1.ReasonTargetNotFound, message)
+	} else {
+		setCondition(obj, v1alpha1.ConditionTargetFound, metav1.ConditionTrue, v1alpha1.ReasonTargetFound, "")
+	}
 
-		output, err := expr.Run(program, env)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+	vpaKey := client.ObjectKey{Namespace: obj.Namespace, Name: targetRefVPAName(obj)}
+	res, err := r.targets().reconcileTarget(ctx, src, obj.Spec.TargetRef, vpaTarget, vpaKey,
+		obj.Status.History, obj.Status.PendingTransition)
 
-		if output.(bool) {
-			matchedPolicy = &policy
-			break
-		}
+	obj.Status.MatchedPolicyIndex, obj.Status.MatchedPolicyName = matchedPolicyStatus(src.policies, res.matchedIndex)
+	obj.Status.NoMatchAction = res.noMatchAction
+	obj.Status.DryRun = res.dryRun
+	obj.Status.History = res.history
+	obj.Status.PendingTransition = res.pending
+	if res.vpaWritten() {
+		obj.Status.VPALastUpdateTime = metav1.NewTime(time.Now().In(time.UTC))
 	}
 
-	if matchedPolicy == nil {
-		return ctrl.Result{}, errors.New("no matching policy found")
+	var exprErr *expressionError
+	if errors.As(err, &exprErr) {
+		setCondition(obj, v1alpha1.ConditionExpressionError, metav1.ConditionTrue, exprErr.reason, err.Error())
+		setCondition(obj, v1alpha1.ConditionPolicyMatched, metav1.ConditionUnknown, exprErr.reason, "")
+		return ctrl.Result{}, err
+	}
+	setCondition(obj, v1alpha1.ConditionExpressionError, metav1.ConditionFalse, v1alpha1.ReasonNoError, "")
+
+	switch {
+	case res.matchedIndex < 0 && res.noMatchActed():
+		setCondition(obj, v1alpha1.ConditionPolicyMatched, metav1.ConditionFalse, v1alpha1.ReasonNoMatch,
+			fmt.Sprintf("no policy condition evaluated to true, noMatchAction is %s", res.noMatchAction))
+	case res.matchedIndex < 0:
+		setCondition(obj, v1alpha1.ConditionPolicyMatched, metav1.ConditionFalse, v1alpha1.ReasonNoMatch, "no policy condition evaluated to true")
+	case res.pending != nil:
+		setCondition(obj, v1alpha1.ConditionPolicyMatched, metav1.ConditionTrue, v1alpha1.ReasonTransitionPending,
+			fmt.Sprintf("policy %s matched, keeping policy %s until %s",
+				policyDisplayName(int(res.pending.To), &src.policies[res.pending.To]),
+				policyDisplayName(res.matchedIndex, &src.policies[res.matchedIndex]),
+				res.pending.EligibleAt.Format(time.RFC3339)))
+	case res.skipped:
+		setCondition(obj, v1alpha1.ConditionPolicyMatched, metav1.ConditionTrue, v1alpha1.ReasonPolicySkipped,
+			fmt.Sprintf("policy %s matched, skipping reconciliation", policyDisplayName(res.matchedIndex, &src.policies[res.matchedIndex])))
+	default:
+		setCondition(obj, v1alpha1.ConditionPolicyMatched, metav1.ConditionTrue, v1alpha1.ReasonMatched,
+			fmt.Sprintf("policy %s matched", policyDisplayName(res.matchedIndex, &src.policies[res.matchedIndex])))
 	}
 
-	if matchedPolicy.Skip {
-		logger.V(5).Info("Skipping reconciliation")
-		return defaultResult, nil
+	var rErr *reconcileError
+	if errors.As(err, &rErr) && rErr.reason == v1alpha1.ReasonSyncFailed {
+		setCondition(obj, v1alpha1.ConditionVPASynced, metav1.ConditionFalse, v1alpha1.ReasonSyncFailed, err.Error())
+	} else if len(res.conflict) > 0 {
+		setCondition(obj, v1alpha1.ConditionVPASynced, metav1.ConditionFalse, res.syncReason, res.conflict)
+	} else if res.syncReason == v1alpha1.ReasonDryRun {
+		setCondition(obj, v1alpha1.ConditionVPASynced, metav1.ConditionFalse, v1alpha1.ReasonDryRun,
+			fmt.Sprintf("the VerticalPodAutoscaler would be %sd in DryRun mode", strings.ToLower(string(res.dryRun.Action))))
+	} else if len(res.syncReason) > 0 {
+		setCondition(obj, v1alpha1.ConditionVPASynced, metav1.ConditionTrue, res.syncReason, "")
+	}
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	logger.V(5).Info("Reconciling",
-		"policy", matchedPolicy.Condition,
-	)
+	if err := r.targets().deleteStaleVPAs(ctx, src, sets.New(vpaKey)); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	wantVpaSpec := makeVpaSpec(&obj, &matchedPolicy.VpaSpec)
+	now := time.Now()
+	return boundaryResult(pendingResult(r.defaultResult(), &obj.Status, now), res.nextBoundary, now), nil
+}
 
-	// Get or create the VPA object
-	foundVPA := &vpa.VerticalPodAutoscaler{}
-	if err := r.Get(ctx, client.ObjectKey{Name: obj.Name, Namespace: obj.Namespace}, foundVPA); err != nil {
-		if client.IgnoreNotFound(err) != nil {
-			return ctrl.Result{}, err
-		}
+// targetRefVPAName returns the name of the VerticalPodAutoscaler of the targetRef of obj.
+func targetRefVPAName(obj *v1alpha1.DynamicVerticalPodAutoscaler) string {
+	if len(obj.Spec.VPAName) > 0 {
+		return obj.Spec.VPAName
+	}
+	return obj.Name
+}
 
-		logger.V(5).Info("Creating VerticalPodAutoscaler")
+// programCache returns the cache of compiled conditions, creating it on first use.
+func (r *DynamicVerticalPodAutoscalerReconciler) programCache() *programCache {
+	r.programsOnce.Do(func() {
+		r.programs = newProgramCache(r.ProgramCacheSize)
+	})
+	return r.programs
+}
 
-		want := &vpa.VerticalPodAutoscaler{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      obj.Name,
-				Namespace: obj.Namespace,
-			},
-			Spec: wantVpaSpec,
-		}
+// targets returns the targetReconciler evaluating the policies of the DynamicVerticalPodAutoscalers.
+func (r *DynamicVerticalPodAutoscalerReconciler) targets() *targetReconciler {
+	return &targetReconciler{
+		Client:      r.Client,
+		scheme:      r.Scheme,
+		recorder:    r.Recorder,
+		programs:    r.programCache(),
+		historySize: r.HistorySize,
+		maxTargets:  r.MaxTargets,
+		podsIndexed: r.PodsIndexed,
+		cluster:     r.ClusterFacts,
+		workloads:   newWorkloadKinds(r.AllowedKinds),
+	}
+}
 
-		if err := controllerutil.SetControllerReference(&obj, want, r.Scheme); err != nil {
-			return ctrl.Result{}, err
+// validateSpec performs the sanity checks that do not require any lookup.
+func validateSpec(obj *v1alpha1.DynamicVerticalPodAutoscaler, kinds workloadKinds) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	targetRefPath := specPath.Child("targetRef")
+	targetSelectorPath := specPath.Child("targetSelector")
+	switch {
+	case obj.Spec.TargetRef != nil && obj.Spec.TargetSelector != nil:
+		errs = append(errs, field.Forbidden(targetSelectorPath, "targetRef and targetSelector are mutually exclusive"))
+	case obj.Spec.TargetRef == nil && obj.Spec.TargetSelector == nil:
+		errs = append(errs, field.Required(targetRefPath, "one of targetRef or targetSelector is required"))
+	case obj.Spec.TargetSelector != nil:
+		errs = append(errs, validateTargetSelector(obj.Spec.TargetSelector, kinds, targetSelectorPath)...)
+	default:
+		if len(obj.Spec.TargetRef.Kind) == 0 {
+			errs = append(errs, field.Required(targetRefPath.Child("kind"), ""))
 		}
-
-		if err := r.Create(ctx, want); err != nil {
-			return ctrl.Result{}, err
+		errs = append(errs, kinds.validateKind(obj.Spec.TargetRef.APIVersion, obj.Spec.TargetRef.Kind, targetRefPath.Child("kind"))...)
+		if len(obj.Spec.TargetRef.APIVersion) == 0 {
+			errs = append(errs, field.Required(targetRefPath.Child("apiVersion"), ""))
+		} else if _, err := schema.ParseGroupVersion(obj.Spec.TargetRef.APIVersion); err != nil {
+			errs = append(errs, field.Invalid(targetRefPath.Child("apiVersion"), obj.Spec.TargetRef.APIVersion, err.Error()))
 		}
-
-		obj.Status.VPALastUpdateTime = metav1.NewTime(time.Now().In(time.UTC))
-		if err := r.Status().Update(ctx, &obj); err != nil {
-			return ctrl.Result{}, err
+		if len(obj.Spec.TargetRef.Name) == 0 {
+			errs = append(errs, field.Required(targetRefPath.Child("name"), ""))
 		}
+	}
 
-	} else {
-		logger.V(5).Info("Found existing VerticalPodAutoscaler")
-		if err := controllerutil.SetControllerReference(&obj, foundVPA, r.Scheme); err != nil {
-			return ctrl.Result{}, err
+	if len(obj.Spec.VPAName) > 0 {
+		if obj.Spec.TargetRef == nil {
+			errs = append(errs, field.Forbidden(specPath.Child("vpaName"), "only allowed with targetRef, use vpaNameTemplate"))
+		} else if msgs := validation.IsDNS1123Subdomain(obj.Spec.VPAName); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(specPath.Child("vpaName"), obj.Spec.VPAName, strings.Join(msgs, ", ")))
 		}
-		if !reflect.DeepEqual(foundVPA.Spec, wantVpaSpec) {
-			foundVPA.Spec = wantVpaSpec
-			if err := r.Update(ctx, foundVPA); err != nil {
-				return ctrl.Result{}, err
-			}
-			obj.Status.VPALastUpdateTime = metav1.NewTime(time.Now().In(time.UTC))
-			if err := r.Status().Update(ctx, &obj); err != nil {
-				return ctrl.Result{}, err
-			}
+	}
+	if len(obj.Spec.VPANameTemplate) > 0 {
+		if obj.Spec.TargetSelector == nil {
+			errs = append(errs, field.Forbidden(specPath.Child("vpaNameTemplate"), "only allowed with targetSelector, use vpaName"))
 		} else {
-			logger.V(5).Info("No update needed")
+			errs = append(errs, validateVPANameTemplate(obj.Spec.VPANameTemplate, specPath.Child("vpaNameTemplate"))...)
 		}
 	}
 
-	return defaultResult, nil
-}
-
-// getProgramEnv returns the environment available in the expr-lang condition
-func (r *DynamicVerticalPodAutoscalerReconciler) getProgramEnv(
-	obj v1alpha1.DynamicVerticalPodAutoscaler,
-	existingVpa *vpa.VerticalPodAutoscaler,
-	vpaTarget *unstructured.Unstructured,
-) (map[string]interface{}, error) {
-
-	var objUnstructured = &unstructured.Unstructured{}
-	if err := r.Scheme.Convert(&obj, objUnstructured, nil); err != nil {
-		return nil, err
-	}
+	errs = append(errs, validateNoMatchAction(obj.Spec.NoMatchAction, obj.Spec.DefaultVpaSpec, specPath)...)
 
-	var vpaUnstructured = &unstructured.Unstructured{}
-	if existingVpa != nil {
-		if err := r.Scheme.Convert(existingVpa, vpaUnstructured, nil); err != nil {
-			return nil, err
+	if ref := obj.Spec.PolicyTemplateRef; ref != nil {
+		if len(ref.Name) == 0 {
+			errs = append(errs, field.Required(specPath.Child("policyTemplateRef", "name"), ""))
 		}
+	} else if len(obj.Spec.Policies) == 0 {
+		errs = append(errs, field.Required(specPath.Child("policies"), "at least one policy is required"))
 	}
+	return errs
+}
 
+// programEnv returns the environment of the conditions with the given variables, no namespace nor cluster,
+// an empty history, no pods, the helpers evaluating time windows and the functions of helperFuncs.
+// The variables are always typed, so that programs compiled against an environment
+// can run against another one, even when some variables are nil.
+func programEnv(target, vpa, obj map[string]interface{}) map[string]interface{} {
 	env := map[string]interface{}{
-		"target": vpaTarget.Object,
-		"vpa":    vpaUnstructured.Object,
-		"obj":    objUnstructured.Object,
+		"target":    target,
+		"vpa":       vpa,
+		"obj":       obj,
+		"namespace": map[string]interface{}(nil),
+		"cluster":   map[string]interface{}(nil),
 	}
-
-	return env, nil
+	setHistory(env, nil)
+	setPods(env, nil, time.Time{})
+	setWindows(env)
+	setHelpers(env)
+	return env
 }
 
-func makeVpaSpec(owner *v1alpha1.DynamicVerticalPodAutoscaler, wantSpec *v1alpha1.VpaSpec) vpa.VerticalPodAutoscalerSpec {
-	return vpa.VerticalPodAutoscalerSpec{
-		TargetRef:      owner.Spec.TargetRef,
-		UpdatePolicy:   wantSpec.UpdatePolicy,
-		ResourcePolicy: wantSpec.ResourcePolicy,
-		Recommenders:   wantSpec.Recommenders,
-	}
+// targetGVK returns the GroupVersionKind of the target of obj.
+// The apiVersion must have been validated beforehand.
+func targetGVK(obj *v1alpha1.DynamicVerticalPodAutoscaler) schema.GroupVersionKind {
+	targetGV, _ := schema.ParseGroupVersion(obj.Spec.TargetRef.APIVersion)
+	return targetGV.WithKind(obj.Spec.TargetRef.Kind)
 }
 
-// findVPATarget finds the VPA target object. Result may be nil
+// getVPATarget gets the VPA target object. Its kind has been validated against the allowed kinds,
+// and it is normalised according to its kind when the program environment is built.
 func (r *DynamicVerticalPodAutoscalerReconciler) getVPATarget(ctx context.Context, obj v1alpha1.DynamicVerticalPodAutoscaler) (*unstructured.Unstructured, error) {
-	targetGV, err := schema.ParseGroupVersion(obj.Spec.TargetRef.APIVersion)
-	if err != nil {
-		return nil, err
-	}
-	targetGvk := targetGV.WithKind(obj.Spec.TargetRef.Kind)
-
 	var target = unstructured.Unstructured{}
 	target.SetNamespace(obj.Namespace)
-	target.SetGroupVersionKind(targetGvk)
+	target.SetGroupVersionKind(targetGVK(&obj))
 	target.SetName(obj.Spec.TargetRef.Name)
 	if err := r.Get(ctx, client.ObjectKeyFromObject(&target), &target); err != nil {
 		return nil, err
@@ -253,10 +420,71 @@ func (r *DynamicVerticalPodAutoscalerReconciler) getVPATarget(ctx context.Contex
 	return &target, nil
 }
 
+// findObjectsForNamespace maps a namespace to the DynamicVerticalPodAutoscalers it contains,
+// whose conditions may read its labels and annotations.
+func (r *DynamicVerticalPodAutoscalerReconciler) findObjectsForNamespace(ctx context.Context, namespace client.Object) []reconcile.Request {
+	var list v1alpha1.DynamicVerticalPodAutoscalerList
+	if err := r.List(ctx, &list, client.InNamespace(namespace.GetName())); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list DynamicVerticalPodAutoscalers for namespace",
+			"namespace", namespace.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
+// The targets are watched dynamically, as their kinds are only known once the objects are reconciled.
 func (r *DynamicVerticalPodAutoscalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.DynamicVerticalPodAutoscaler{}).
-		Owns(&vpa.VerticalPodAutoscaler{}).
-		Complete(r)
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor(eventRecorderName)
+	}
+	if r.VPACRD == nil {
+		r.VPACRD = NewVPACRD(mgr)
+	}
+	if r.ClusterFacts == nil {
+		clusterFacts, err := NewClusterFacts(context.Background(), mgr)
+		if err != nil {
+			return err
+		}
+		r.ClusterFacts = clusterFacts
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.DynamicVerticalPodAutoscaler{}, targetRefIndexKey, indexTargetRef); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.DynamicVerticalPodAutoscaler{}, targetKindsIndexKey, indexTargetKinds); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.DynamicVerticalPodAutoscaler{}, policyTemplateIndexKey, indexPolicyTemplate); err != nil {
+		return err
+	}
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		// Status updates do not bump the generation, so they do not trigger a new reconciliation.
+		// Deletions may not bump the generation either, and must remove the finalizer.
+		For(&v1alpha1.DynamicVerticalPodAutoscaler{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, deletionPredicate))).
+		Watches(&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForNamespace),
+			builder.WithPredicates(namespacePredicate)).
+		Watches(&v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate{},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForPolicyTemplate),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// The VerticalPodAutoscalers are not watched with Owns, as the controller would fail to start
+	// without their CRD.
+	r.VPACRD.watch(c, &v1alpha1.DynamicVerticalPodAutoscaler{})
+	r.targetWatcher = newTargetWatcher(mgr, c, handler.EnqueueRequestsFromMapFunc(r.findObjectsForTarget))
+	return nil
 }
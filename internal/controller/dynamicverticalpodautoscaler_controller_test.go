@@ -18,14 +18,24 @@ package controller
 
 import (
 	"context"
+	"strings"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
 	autoscaling "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -74,7 +84,7 @@ var _ = Describe("DynamicVerticalPodAutoscaler Controller", func() {
 					},
 				},
 			}
-			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, deployment))).To(Succeed())
 
 			By("creating the custom resource for the Kind DynamicVerticalPodAutoscaler")
 			err := k8sClient.Get(ctx, typeNamespacedName, obj)
@@ -118,10 +128,21 @@ var _ = Describe("DynamicVerticalPodAutoscaler Controller", func() {
 			// TODO(user): Cleanup logic after each test, like removing the resource instance.
 			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			if errors.IsNotFound(err) {
+				// Already deleted by the specs of the deletionPolicy.
+				err = nil
+			} else if err == nil {
+				By("Cleanup the specific resource instance DynamicVerticalPodAutoscaler")
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+				_, err = (&DynamicVerticalPodAutoscalerReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}).
+					Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			}
 			Expect(err).NotTo(HaveOccurred())
 
-			By("Cleanup the specific resource instance DynamicVerticalPodAutoscaler")
-			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			// The VerticalPodAutoscalers are not garbage collected, as envtest runs no controller manager.
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &vpa.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+			}))).To(Succeed())
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
@@ -149,6 +170,13 @@ var _ = Describe("DynamicVerticalPodAutoscaler Controller", func() {
 			err = k8sClient.Get(ctx, typeNamespacedName, updatedResource)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(updatedResource.Status.VPALastUpdateTime).NotTo(Equal(0))
+			Expect(updatedResource.Status.ObservedGeneration).To(Equal(updatedResource.Generation))
+			Expect(updatedResource.Status.MatchedPolicyIndex).To(Equal(ptr.To(int32(1))))
+			Expect(meta.IsStatusConditionTrue(updatedResource.Status.Conditions, v1alpha1.ConditionReady)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(updatedResource.Status.Conditions, v1alpha1.ConditionPolicyMatched)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(updatedResource.Status.Conditions, v1alpha1.ConditionTargetFound)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(updatedResource.Status.Conditions, v1alpha1.ConditionVPASynced)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(updatedResource.Status.Conditions, v1alpha1.ConditionExpressionError)).To(BeTrue())
 
 			By("Changing the condition")
 			updatedResource.Spec.Policies[0].Condition = "true"
@@ -165,5 +193,888 @@ var _ = Describe("DynamicVerticalPodAutoscaler Controller", func() {
 
 		})
 
+		// existingVPA returns a VerticalPodAutoscaler of the deployment with the given updateMode,
+		// not created by the controller.
+		existingVPA := func(updateMode *vpa.UpdateMode) *vpa.VerticalPodAutoscaler {
+			return &vpa.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: vpa.VerticalPodAutoscalerSpec{
+					TargetRef: &autoscaling.CrossVersionObjectReference{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       resourceName,
+					},
+					UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: updateMode},
+					ResourcePolicy: &vpa.PodResourcePolicy{
+						ContainerPolicies: []vpa.ContainerResourcePolicy{{ContainerName: resourceName}},
+					},
+				},
+			}
+		}
+
+		It("should report a conflict on a field owned by another manager", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Taking the ownership of the updateMode with another manager")
+			applied := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": vpa.SchemeGroupVersion.String(),
+				"kind":       "VerticalPodAutoscaler",
+				"metadata":   map[string]interface{}{"name": resourceName, "namespace": "default"},
+				"spec": map[string]interface{}{
+					"updatePolicy": map[string]interface{}{"updateMode": string(updateModeAuto)},
+				},
+			}}
+			Expect(k8sClient.Patch(ctx, applied, client.Apply, client.FieldOwner("other"), client.ForceOwnership)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).To(HaveOccurred())
+
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			ready := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal(v1alpha1.ReasonFieldConflict))
+			synced := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionVPASynced)
+			Expect(synced.Status).To(Equal(metav1.ConditionFalse))
+			Expect(synced.Reason).To(Equal(v1alpha1.ReasonFieldConflict))
+
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeAuto))
+
+			By("Forcing the ownership of the conflicting fields")
+			resource.Spec.Force = true
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeOff))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, v1alpha1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should take over the VerticalPodAutoscalers written with updates", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			By("Creating a VerticalPodAutoscaler as previous versions of the controller")
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			legacy := existingVPA(&updateModeRecreate)
+			Expect(controllerutil.SetControllerReference(resource, legacy, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, legacy, client.FieldOwner(legacyFieldManager))).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Owning every field written by the previous versions")
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeOff))
+			Expect(vpaResource.Spec.ResourcePolicy).To(BeNil())
+			Expect(appliedBy(vpaResource, fieldManager)).To(BeTrue())
+			for _, entry := range vpaResource.ManagedFields {
+				Expect(entry.Manager).NotTo(Equal(legacyFieldManager))
+			}
+		})
+
+		It("should only adopt the VerticalPodAutoscalers allowed by the adoptionPolicy", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			By("Creating a VerticalPodAutoscaler without controller")
+			Expect(k8sClient.Create(ctx, existingVPA(&updateModeOff), client.FieldOwner("helm"))).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).To(HaveOccurred())
+
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			ready := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal(v1alpha1.ReasonAdoptionConflict))
+
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(metav1.GetControllerOf(vpaResource)).To(BeNil())
+			Expect(vpaResource.Labels).NotTo(HaveKey(v1alpha1.DynamicVerticalPodAutoscalerLabel))
+
+			By("Adopting it with the IfUnowned adoptionPolicy")
+			resource.Spec.AdoptionPolicy = v1alpha1.AdoptionPolicyIfUnowned
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(metav1.IsControlledBy(vpaResource, resource)).To(BeTrue())
+			By("Keeping the fields set by the previous manager")
+			Expect(vpaResource.Spec.ResourcePolicy).NotTo(BeNil())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			synced := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionVPASynced)
+			Expect(synced.Reason).To(Equal(v1alpha1.ReasonAdopted))
+		})
+
+		It("should take over the fields of the adopted VerticalPodAutoscalers set by the policy", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			By("Creating a VerticalPodAutoscaler with another updateMode as another manager")
+			Expect(k8sClient.Create(ctx, existingVPA(&updateModeRecreate), client.FieldOwner("helm"))).To(Succeed())
+
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.AdoptionPolicy = v1alpha1.AdoptionPolicyAlways
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Applying the policy without force")
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(metav1.IsControlledBy(vpaResource, resource)).To(BeTrue())
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeOff))
+			Expect(vpaResource.Spec.ResourcePolicy).NotTo(BeNil())
+			Expect(appliedBy(vpaResource, fieldManager)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, v1alpha1.ConditionReady)).To(BeTrue())
+
+			By("Applying the next changes of the policy")
+			resource.Spec.Policies[0].Condition = "true"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeRecreate))
+		})
+
+		It("should not adopt the VerticalPodAutoscalers of another controller with IfUnowned", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			By("Creating a VerticalPodAutoscaler controlled by another object")
+			owner := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-owner", Namespace: "default"}}
+			Expect(k8sClient.Create(ctx, owner)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, owner)).To(Succeed())
+			}()
+			controlled := existingVPA(&updateModeRecreate)
+			Expect(controllerutil.SetControllerReference(owner, controlled, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, controlled)).To(Succeed())
+
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.AdoptionPolicy = v1alpha1.AdoptionPolicyIfUnowned
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			ready := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(ready.Reason).To(Equal(v1alpha1.ReasonAdoptionConflict))
+			Expect(ready.Message).To(ContainSubstring("ConfigMap"))
+
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(metav1.IsControlledBy(vpaResource, owner)).To(BeTrue())
+		})
+
+		// deleteWithPolicy deletes the resource with the given deletionPolicy once its VerticalPodAutoscaler
+		// is created, and returns the VerticalPodAutoscaler left after the deletion.
+		deleteWithPolicy := func(policy v1alpha1.DeletionPolicy) *vpa.VerticalPodAutoscaler {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.DeletionPolicy = policy
+			resource.Spec.Policies[1].VpaSpec.UpdatePolicy.UpdateMode = &updateModeAuto
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Adding the finalizer")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(controllerutil.ContainsFinalizer(resource, v1alpha1.Finalizer)).To(BeTrue())
+
+			By("Deleting the resource")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+
+			By("Releasing the VerticalPodAutoscaler")
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(vpaResource.OwnerReferences).To(BeEmpty())
+			Expect(vpaResource.Labels).NotTo(HaveKey(v1alpha1.DynamicVerticalPodAutoscalerLabel))
+			return vpaResource
+		}
+
+		It("should orphan the VerticalPodAutoscalers with the Orphan deletionPolicy", func() {
+			vpaResource := deleteWithPolicy(v1alpha1.DeletionPolicyOrphan)
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeAuto))
+		})
+
+		It("should turn off the VerticalPodAutoscalers with the ResetToOff deletionPolicy", func() {
+			vpaResource := deleteWithPolicy(v1alpha1.DeletionPolicyResetToOff)
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeOff))
+		})
+
+		It("should not modify the VerticalPodAutoscalers when deleted in DryRun mode", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Policies[1].VpaSpec.UpdatePolicy.UpdateMode = &updateModeAuto
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Deleting the resource in DryRun mode with the ResetToOff deletionPolicy")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Mode = v1alpha1.ModeDryRun
+			resource.Spec.DeletionPolicy = v1alpha1.DeletionPolicyResetToOff
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			uid := resource.UID
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+
+			By("Leaving the VerticalPodAutoscaler unchanged")
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			Expect(metav1.GetControllerOf(vpaResource)).NotTo(BeNil())
+			Expect(metav1.GetControllerOf(vpaResource).UID).To(Equal(uid))
+			Expect(vpaResource.Labels).To(HaveKeyWithValue(v1alpha1.DynamicVerticalPodAutoscalerLabel, resourceName))
+			Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(&updateModeAuto))
+		})
+
+		It("should delete the resource while the VerticalPodAutoscaler CRD is not installed", func() {
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.DeletionPolicy = v1alpha1.DeletionPolicyOrphan
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := (&DynamicVerticalPodAutoscalerReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}).
+				Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Uninstalling the VerticalPodAutoscaler CRD")
+			withWatch, err := client.NewWithWatch(cfg, client.Options{Scheme: k8sClient.Scheme()})
+			Expect(err).NotTo(HaveOccurred())
+			crdMissing := interceptor.NewClient(withWatch, interceptor.Funcs{
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					if _, ok := list.(*vpa.VerticalPodAutoscalerList); ok {
+						return &meta.NoKindMatchError{
+							GroupKind:        vpa.SchemeGroupVersion.WithKind("VerticalPodAutoscaler").GroupKind(),
+							SearchedVersions: []string{vpa.SchemeGroupVersion.Version},
+						}
+					}
+					return c.List(ctx, list, opts...)
+				},
+			})
+
+			By("Deleting the resource")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = (&DynamicVerticalPodAutoscalerReconciler{Client: crdMissing, Scheme: k8sClient.Scheme()}).
+				Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+		})
+
+		DescribeTable("should apply the noMatchAction when no policy matches",
+			func(action v1alpha1.NoMatchAction, wantUpdateMode *vpa.UpdateMode, wantReady bool) {
+				controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+					Client: k8sClient,
+					Scheme: k8sClient.Scheme(),
+				}
+				reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+				_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+				Expect(err).NotTo(HaveOccurred())
+
+				By("Matching no policy")
+				resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+				resource.Spec.Policies[1].Condition = "false"
+				resource.Spec.NoMatchAction = action
+				if action == v1alpha1.NoMatchActionDefault {
+					resource.Spec.DefaultVpaSpec = &v1alpha1.VpaSpec{
+						UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeAuto},
+					}
+				}
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+				_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+				if wantReady {
+					Expect(err).NotTo(HaveOccurred())
+				} else {
+					Expect(err).To(MatchError(errNoMatchingPolicy))
+				}
+
+				By("Reporting the noMatchAction in the status")
+				Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+				Expect(resource.Status.NoMatchAction).To(Equal(noMatchActionOrDefault(action)))
+				Expect(resource.Status.MatchedPolicyIndex).To(BeNil())
+				matched := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionPolicyMatched)
+				Expect(matched.Status).To(Equal(metav1.ConditionFalse))
+				Expect(matched.Reason).To(Equal(v1alpha1.ReasonNoMatch))
+				Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, v1alpha1.ConditionReady)).To(Equal(wantReady))
+
+				By("Applying the noMatchAction to the VerticalPodAutoscaler")
+				vpaResource := &vpa.VerticalPodAutoscaler{}
+				err = k8sClient.Get(ctx, typeNamespacedName, vpaResource)
+				if wantUpdateMode == nil {
+					Expect(errors.IsNotFound(err)).To(BeTrue())
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(vpaResource.Spec.UpdatePolicy.UpdateMode).To(Equal(wantUpdateMode))
+				}
+			},
+			Entry("Error by default", v1alpha1.NoMatchAction(""), &updateModeOff, false),
+			Entry("Keep", v1alpha1.NoMatchActionKeep, &updateModeOff, true),
+			Entry("Delete", v1alpha1.NoMatchActionDelete, nil, true),
+			Entry("Default", v1alpha1.NoMatchActionDefault, &updateModeAuto, true),
+		)
+
+		It("should not write an unchanged status", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			// The second reconciliation reports the created VerticalPodAutoscaler as UpToDate.
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionVPASynced).Reason).To(Equal(v1alpha1.ReasonUpToDate))
+
+			By("Reconciling again without any change")
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			unchanged := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, unchanged)).To(Succeed())
+			Expect(unchanged.ResourceVersion).To(Equal(resource.ResourceVersion))
+			Expect(unchanged.Status.LastEvaluationTime).To(Equal(resource.Status.LastEvaluationTime))
+		})
+
+		It("should not report the fields set by other managers in DryRun mode", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Setting a field of the VerticalPodAutoscaler with another manager")
+			vpaResource := &vpa.VerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, vpaResource)).To(Succeed())
+			vpaResource.Spec.ResourcePolicy = &vpa.PodResourcePolicy{
+				ContainerPolicies: []vpa.ContainerResourcePolicy{{ContainerName: resourceName}},
+			}
+			Expect(k8sClient.Update(ctx, vpaResource, client.FieldOwner("other"))).To(Succeed())
+
+			By("Switching to DryRun mode")
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Mode = v1alpha1.ModeDryRun
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.DryRun).NotTo(BeNil())
+			Expect(resource.Status.DryRun.Action).To(Equal(v1alpha1.DryRunActionNone))
+			Expect(resource.Status.DryRun.Diff).To(BeEmpty())
+
+			By("Changing the matched policy")
+			resource.Spec.Policies[0].Condition = "true"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.DryRun.Action).To(Equal(v1alpha1.DryRunActionUpdate))
+			Expect(resource.Status.DryRun.Diff).To(Equal([]string{"updatePolicy.updateMode: Off -> Recreate"}))
+		})
+
+		It("should report errors in the status", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			By("Making every condition evaluate to false")
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Policies[1].Condition = "false"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.MatchedPolicyIndex).To(BeNil())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, v1alpha1.ConditionPolicyMatched)).To(BeTrue())
+			ready := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal(v1alpha1.ReasonNoMatch))
+
+			By("Introducing a condition that does not compile")
+			resource.Spec.Policies[0].Condition = "target.metadata.name =="
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, v1alpha1.ConditionExpressionError)).To(BeTrue())
+			ready = meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(ready.Reason).To(Equal(v1alpha1.ReasonCompileError))
+		})
+
+		It("should not report the steps that did not run", func() {
+			controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Making the spec invalid")
+			resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, v1alpha1.ConditionVPASynced)).To(BeTrue())
+			resource.Spec.TargetRef.APIVersion = "apps/v1/invalid"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			for _, conditionType := range []string{
+				v1alpha1.ConditionTargetFound,
+				v1alpha1.ConditionPolicyMatched,
+				v1alpha1.ConditionVPASynced,
+				v1alpha1.ConditionExpressionError,
+			} {
+				condition := meta.FindStatusCondition(resource.Status.Conditions, conditionType)
+				Expect(condition).NotTo(BeNil(), conditionType)
+				Expect(condition.Status).To(Equal(metav1.ConditionUnknown), conditionType)
+				Expect(condition.Reason).To(Equal(v1alpha1.ReasonInvalidSpec), conditionType)
+			}
+			ready := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(ready.Reason).To(Equal(v1alpha1.ReasonInvalidSpec))
+		})
+
+	})
+})
+
+var _ = Describe("DynamicVerticalPodAutoscaler Controller with a targetSelector", func() {
+	const resourceName = "test-selector"
+
+	ctx := context.Background()
+	typeNamespacedName := types.NamespacedName{Name: resourceName, Namespace: "default"}
+
+	newDeployment := func(name, team string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"team": team},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": name},
+				},
+				Template: v1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{Name: name, Image: "nginx"}},
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		By("creating the selected deployments")
+		Expect(k8sClient.Create(ctx, newDeployment(resourceName+"-a", "selector"))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newDeployment(resourceName+"-b", "selector"))).To(Succeed())
+
+		resource := &v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetSelector: &v1alpha1.TargetSelector{
+					Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "selector"}},
+				},
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{
+					VpaSpec: v1alpha1.VpaSpec{
+						UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff},
+					},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		_, err := (&DynamicVerticalPodAutoscalerReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}).
+			Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should manage one VerticalPodAutoscaler per selected workload", func() {
+		controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+		}
+		reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+		_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating a VerticalPodAutoscaler for each deployment")
+		vpaA := &vpa.VerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{
+			Name: resourceName + "-deployment-" + resourceName + "-a", Namespace: "default"}, vpaA)).To(Succeed())
+		Expect(vpaA.Spec.TargetRef.Name).To(Equal(resourceName + "-a"))
+		vpaB := &vpa.VerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{
+			Name: resourceName + "-deployment-" + resourceName + "-b", Namespace: "default"}, vpaB)).To(Succeed())
+
+		resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(resource.Status.Targets).To(HaveLen(2))
+
+		By("deleting the VerticalPodAutoscaler of a deployment that is no longer selected")
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-b", Namespace: "default"}, deployment)).To(Succeed())
+		deployment.Labels["team"] = "other"
+		Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+		_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(vpaB), vpaB)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(resource.Status.Targets).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("DynamicVerticalPodAutoscaler Controller with more targets than managed", func() {
+	const resourceName = "test-max-targets"
+
+	ctx := context.Background()
+	typeNamespacedName := types.NamespacedName{Name: resourceName, Namespace: "default"}
+	names := []string{resourceName + "-a", resourceName + "-b", resourceName + "-c"}
+
+	BeforeEach(func() {
+		for _, name := range names {
+			Expect(k8sClient.Create(ctx, &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+					Labels:    map[string]string{"team": resourceName},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+					Template: v1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+						Spec:       v1.PodSpec{Containers: []v1.Container{{Name: name, Image: "nginx"}}},
+					},
+				},
+			})).To(Succeed())
+		}
+
+		Expect(k8sClient.Create(ctx, &v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetSelector: &v1alpha1.TargetSelector{
+					Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": resourceName}},
+				},
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{
+					VpaSpec: v1alpha1.VpaSpec{
+						UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff},
+					},
+				}},
+			},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		_, err := (&DynamicVerticalPodAutoscalerReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}).
+			Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+		for _, name := range names {
+			deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+			Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+		}
+	})
+
+	It("should only manage the first targets", func() {
+		controllerReconciler := &DynamicVerticalPodAutoscalerReconciler{
+			Client:     k8sClient,
+			Scheme:     k8sClient.Scheme(),
+			MaxTargets: 2,
+		}
+		reconcileReq := reconcile.Request{NamespacedName: typeNamespacedName}
+
+		_, err := controllerReconciler.Reconcile(ctx, reconcileReq)
+		Expect(err).To(HaveOccurred())
+
+		resource := &v1alpha1.DynamicVerticalPodAutoscaler{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(resource.Status.Targets).To(HaveLen(2))
+		Expect(resource.Status.Targets[0].Name).To(Equal(names[0]))
+		Expect(resource.Status.Targets[1].Name).To(Equal(names[1]))
+		ready := meta.FindStatusCondition(resource.Status.Conditions, v1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(v1alpha1.ReasonTooManyTargets))
+
+		By("leaving the workloads beyond the limit without VerticalPodAutoscaler")
+		err = k8sClient.Get(ctx, types.NamespacedName{
+			Name: resourceName + "-deployment-" + names[2], Namespace: "default"}, &vpa.VerticalPodAutoscaler{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		By("managing every workload within the limit")
+		controllerReconciler.MaxTargets = 3
+		_, err = controllerReconciler.Reconcile(ctx, reconcileReq)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+		Expect(resource.Status.Targets).To(HaveLen(3))
+		Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, v1alpha1.ConditionReady)).To(BeTrue())
+	})
+})
+
+var _ = Describe("managedTargetsFirst", func() {
+	newTarget := func(namespace, name string) unstructured.Unstructured {
+		target := unstructured.Unstructured{}
+		target.SetAPIVersion("apps/v1")
+		target.SetKind("Deployment")
+		target.SetNamespace(namespace)
+		target.SetName(name)
+		return target
+	}
+
+	It("should keep the listed targets first", func() {
+		targets := []unstructured.Unstructured{
+			newTarget("default", "d"), newTarget("default", "a"), newTarget("default", "c"), newTarget("default", "b"),
+		}
+		statuses := []v1alpha1.TargetStatus{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "c"},
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "removed"},
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "b"},
+		}
+
+		var names []string
+		for _, target := range managedTargetsFirst(targets, statuses, false) {
+			names = append(names, target.GetName())
+		}
+		Expect(names).To(Equal([]string{"c", "b", "a", "d"}))
+	})
+
+	It("should sort the targets of cluster-wide objects by namespace", func() {
+		targets := []unstructured.Unstructured{newTarget("b", "a"), newTarget("a", "b")}
+		statuses := []v1alpha1.TargetStatus{{APIVersion: "apps/v1", Kind: "Deployment", Name: "a"}}
+
+		var names []string
+		for _, target := range managedTargetsFirst(targets, statuses, true) {
+			names = append(names, target.GetNamespace()+"/"+target.GetName())
+		}
+		Expect(names).To(Equal([]string{"a/b", "b/a"}))
+	})
+})
+
+var _ = Describe("selectedVPAName", func() {
+	It("should be derived from the workload", func() {
+		tmpl, err := parseVPANameTemplate("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selectedVPAName(tmpl, vpaNameData{Name: "example", Kind: "Deployment", TargetName: "nginx"})).To(Equal("example-deployment-nginx"))
+	})
+
+	It("should truncate long names", func() {
+		tmpl, err := parseVPANameTemplate("")
+		Expect(err).NotTo(HaveOccurred())
+		name, err := selectedVPAName(tmpl, vpaNameData{Name: strings.Repeat("a", 200), Kind: "Deployment", TargetName: strings.Repeat("b", 200)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(name)).To(BeNumerically("<=", 253))
+		Expect(selectedVPAName(tmpl, vpaNameData{Name: strings.Repeat("a", 200), Kind: "Deployment", TargetName: strings.Repeat("b", 199)})).NotTo(Equal(name))
+	})
+
+	It("should render the vpaNameTemplate", func() {
+		tmpl, err := parseVPANameTemplate("{{ .TargetName }}-{{ .Namespace }}-vpa")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selectedVPAName(tmpl, vpaNameData{Name: "example", Namespace: "shop", Kind: "Deployment", TargetName: "nginx"})).To(Equal("nginx-shop-vpa"))
+	})
+
+	It("should reject invalid names", func() {
+		tmpl, err := parseVPANameTemplate("{{ .Kind }}")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = selectedVPAName(tmpl, vpaNameData{Kind: "Deployment"})
+		Expect(err).To(MatchError(ContainSubstring("invalid VerticalPodAutoscaler name")))
+
+		Expect(validateVPANameTemplate("{{ .Kind }}", field.NewPath("vpaNameTemplate"))).To(HaveLen(1))
+		Expect(validateVPANameTemplate("{{ .Owner }}", field.NewPath("vpaNameTemplate"))).To(HaveLen(1))
+		Expect(validateVPANameTemplate("{{ .TargetName }}-vpa", field.NewPath("vpaNameTemplate"))).To(BeEmpty())
+	})
+})
+
+var _ = Describe("indexTargetRef", func() {
+	It("should index the target by group, kind and name", func() {
+		obj := &v1alpha1.DynamicVerticalPodAutoscaler{
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetRef: &autoscaling.CrossVersionObjectReference{
+					Kind:       "Deployment",
+					Name:       "example",
+					APIVersion: "apps/v1",
+				},
+			},
+		}
+		Expect(indexTargetRef(obj)).To(Equal([]string{"Deployment.apps/example"}))
+	})
+
+	It("should not index objects without a target", func() {
+		Expect(indexTargetRef(&v1alpha1.DynamicVerticalPodAutoscaler{})).To(BeEmpty())
+	})
+})
+
+var _ = Describe("targetPredicate", func() {
+	newTarget := func(generation int64, labels map[string]string, replicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Generation: generation, Labels: labels},
+			Status:     appsv1.DeploymentStatus{Replicas: replicas},
+		}
+	}
+
+	It("should ignore the changes to the status of the targets", func() {
+		Expect(targetPredicate.Update(event.UpdateEvent{
+			ObjectOld: newTarget(1, nil, 1),
+			ObjectNew: newTarget(1, nil, 2),
+		})).To(BeFalse())
+	})
+
+	It("should accept the changes to the spec and labels of the targets", func() {
+		Expect(targetPredicate.Update(event.UpdateEvent{
+			ObjectOld: newTarget(1, nil, 1),
+			ObjectNew: newTarget(2, nil, 1),
+		})).To(BeTrue())
+		Expect(targetPredicate.Update(event.UpdateEvent{
+			ObjectOld: newTarget(1, nil, 1),
+			ObjectNew: newTarget(1, map[string]string{"team": "example"}, 1),
+		})).To(BeTrue())
+	})
+})
+
+var _ = Describe("namespacePredicate", func() {
+	newNamespace := func(labels, annotations map[string]string) *v1.Namespace {
+		return &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Labels: labels, Annotations: annotations},
+			Status:     v1.NamespaceStatus{Phase: v1.NamespaceActive},
+		}
+	}
+
+	It("should accept the changes to the labels and annotations of the namespaces", func() {
+		Expect(namespacePredicate.Update(event.UpdateEvent{
+			ObjectOld: newNamespace(nil, nil),
+			ObjectNew: newNamespace(map[string]string{"environment": "prod"}, nil),
+		})).To(BeTrue())
+		Expect(namespacePredicate.Update(event.UpdateEvent{
+			ObjectOld: newNamespace(nil, nil),
+			ObjectNew: newNamespace(nil, map[string]string{"example.com/tier": "gold"}),
+		})).To(BeTrue())
+	})
+
+	It("should ignore the other changes to the namespaces", func() {
+		terminating := newNamespace(nil, nil)
+		terminating.Status.Phase = v1.NamespaceTerminating
+		Expect(namespacePredicate.Update(event.UpdateEvent{
+			ObjectOld: newNamespace(nil, nil),
+			ObjectNew: terminating,
+		})).To(BeFalse())
+	})
+})
+
+var _ = Describe("findObjectsForNamespace", func() {
+	ctx := context.Background()
+
+	It("should map a namespace to its DynamicVerticalPodAutoscalers", func() {
+		reconciler := &DynamicVerticalPodAutoscalerReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx,
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "namespace-watch"}}))).To(Succeed())
+		obj := &v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "namespace-watch"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetRef: &autoscaling.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "example"},
+				Policies:  []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(ctx, obj)).To(Succeed())
+		}()
+
+		Expect(reconciler.findObjectsForNamespace(ctx, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "namespace-watch"}})).
+			To(ConsistOf(reconcile.Request{NamespacedName: types.NamespacedName{Name: "example", Namespace: "namespace-watch"}}))
+		Expect(reconciler.findObjectsForNamespace(ctx, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}})).To(BeEmpty())
 	})
 })
@@ -0,0 +1,180 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-autoscaling-stackrox-io-v1alpha1-dynamicverticalpodautoscaler,mutating=false,failurePolicy=fail,sideEffects=None,groups=autoscaling.stackrox.io,resources=dynamicverticalpodautoscalers,verbs=create;update,versions=v1alpha1,name=vdynamicverticalpodautoscaler.kb.io,admissionReviewVersions=v1
+
+// DynamicVerticalPodAutoscalerValidator validates DynamicVerticalPodAutoscaler objects
+// before they are stored, so that errors are not only discovered by the reconciler.
+type DynamicVerticalPodAutoscalerValidator struct {
+	// AllowedKinds are the kinds of workloads which can be targeted, as in the reconcilers.
+	// Defaults to DefaultAllowedKinds.
+	AllowedKinds []schema.GroupKind
+}
+
+var _ webhook.CustomValidator = &DynamicVerticalPodAutoscalerValidator{}
+
+// SetupWebhookWithManager registers the validating webhook with the Manager.
+func (v *DynamicVerticalPodAutoscalerValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.DynamicVerticalPodAutoscaler{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *DynamicVerticalPodAutoscalerValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator
+func (v *DynamicVerticalPodAutoscalerValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldTyped, oldOK := oldObj.(*v1alpha1.DynamicVerticalPodAutoscaler)
+	newTyped, newOK := newObj.(*v1alpha1.DynamicVerticalPodAutoscaler)
+	if oldOK && newOK && !updateValidated(newTyped, oldTyped.Spec, newTyped.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *DynamicVerticalPodAutoscalerValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *DynamicVerticalPodAutoscalerValidator) validate(o runtime.Object) error {
+	obj, ok := o.(*v1alpha1.DynamicVerticalPodAutoscaler)
+	if !ok {
+		return fmt.Errorf("expected a DynamicVerticalPodAutoscaler but got %T", o)
+	}
+
+	errs := validateSpec(obj, newWorkloadKinds(v.AllowedKinds))
+	errs = append(errs, validatePolicies(obj.Spec.Policies, obj.Spec.Language)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("DynamicVerticalPodAutoscaler").GroupKind(), obj.Name, errs)
+}
+
+// updateValidated reports whether the update of an object to newObj, changing its spec from oldSpec to
+// newSpec, must be validated. The objects being deleted and the updates leaving the spec unchanged, such as
+// the changes of the finalizers, are admitted, so that an object stored before the validation rules changed
+// can still be finalized and deleted.
+func updateValidated(newObj metav1.Object, oldSpec, newSpec any) bool {
+	return newObj.GetDeletionTimestamp().IsZero() && !equality.Semantic.DeepEqual(oldSpec, newSpec)
+}
+
+// validatePolicies checks that every condition compiles to a boolean expression,
+// and that no policy is shadowed by a preceding policy without condition.
+func validatePolicies(policies []v1alpha1.DynamicVerticalPodAutoscalerPolicy, language v1alpha1.ConditionLanguage) field.ErrorList {
+	var errs field.ErrorList
+	policiesPath := field.NewPath("spec", "policies")
+
+	env := programEnv(nil, nil, nil)
+	catchAll := -1
+	for i, policy := range policies {
+		policyPath := policiesPath.Index(i)
+		if catchAll >= 0 {
+			errs = append(errs, field.Invalid(policyPath, policyDisplayName(i, &policy),
+				fmt.Sprintf("unreachable: policy %d has no condition and always matches", catchAll)))
+		}
+		errs = append(errs, validateDuration(policy.MinDwell, policyPath.Child("minDwell"))...)
+		errs = append(errs, validateDuration(policy.EnterAfter, policyPath.Child("enterAfter"))...)
+		if policy.Schedule != nil {
+			if _, err := policyWindow(policy.Schedule); err != nil {
+				errs = append(errs, field.Invalid(policyPath.Child("schedule"), *policy.Schedule, err.Error()))
+			}
+		}
+		policyLanguage := conditionLanguage(language, &policy)
+		errs = append(errs, validateExpressions(policy.VpaSpec.Expressions, policyLanguage, policyPath.Child("vpaSpec", "expressions"), env)...)
+		if len(policy.Condition) == 0 {
+			// A scheduled policy only matches during its windows.
+			if catchAll < 0 && policy.Schedule == nil {
+				catchAll = i
+			}
+			continue
+		}
+		if _, err := compileCondition(policyLanguage, policy.Condition, env); err != nil {
+			errs = append(errs, field.Invalid(policyPath.Child("condition"), policy.Condition, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateDuration checks that an optional duration is not negative.
+func validateDuration(duration *metav1.Duration, path *field.Path) field.ErrorList {
+	if duration == nil || duration.Duration >= 0 {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(path, duration.Duration.String(), "must not be negative")}
+}
+
+// validateExpressions checks that every expression computing a field of the VpaSpec compiles.
+// The type of the results can only be checked once evaluated.
+func validateExpressions(
+	exprs *v1alpha1.VpaSpecExpressions,
+	language v1alpha1.ConditionLanguage,
+	path *field.Path,
+	env map[string]interface{},
+) field.ErrorList {
+	if exprs == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	validate := func(path *field.Path, expression string) {
+		if len(expression) == 0 {
+			return
+		}
+		if _, err := compileValue(language, expression, env); err != nil {
+			errs = append(errs, field.Invalid(path, expression, err.Error()))
+		}
+	}
+
+	validate(path.Child("updateMode"), exprs.UpdateMode)
+	for i, container := range exprs.ContainerPolicies {
+		containerPath := path.Child("containerPolicies").Index(i)
+		if len(container.ContainerName) == 0 {
+			errs = append(errs, field.Required(containerPath.Child("containerName"), ""))
+		}
+		for name, expression := range container.MinAllowed {
+			validate(containerPath.Child("minAllowed").Key(string(name)), expression)
+		}
+		for name, expression := range container.MaxAllowed {
+			validate(containerPath.Child("maxAllowed").Key(string(name)), expression)
+		}
+		validate(containerPath.Child("controlledResources"), container.ControlledResources)
+	}
+	return errs
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("DynamicVerticalPodAutoscaler Webhook", func() {
+	ctx := context.Background()
+	validator := &DynamicVerticalPodAutoscalerValidator{}
+
+	var obj *v1alpha1.DynamicVerticalPodAutoscaler
+
+	BeforeEach(func() {
+		obj = &v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				TargetRef: &autoscaling.CrossVersionObjectReference{
+					Kind:       "Deployment",
+					Name:       "test",
+					APIVersion: "apps/v1",
+				},
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{
+					{Condition: `target.metadata.annotations?.["vpa-disabled"] == "true" ?? false`},
+					{},
+				},
+			},
+		}
+	})
+
+	// causes returns the field paths of the causes of a validation error.
+	causes := func(err error) []string {
+		statusErr, ok := err.(*apierrors.StatusError)
+		Expect(ok).To(BeTrue())
+		var fields []string
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		return fields
+	}
+
+	It("should accept a valid object", func() {
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject a missing targetRef", func() {
+		obj.Spec.TargetRef = nil
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetRef"))
+	})
+
+	It("should reject incomplete targetRef fields", func() {
+		obj.Spec.TargetRef.Kind = ""
+		obj.Spec.TargetRef.APIVersion = "apps/v1/beta"
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetRef.kind", "spec.targetRef.apiVersion"))
+	})
+
+	It("should accept a targetSelector", func() {
+		obj.Spec.TargetRef = nil
+		obj.Spec.TargetSelector = &v1alpha1.TargetSelector{
+			Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "example"}},
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject a targetRef combined with a targetSelector", func() {
+		obj.Spec.TargetSelector = &v1alpha1.TargetSelector{
+			Kinds:    []v1alpha1.TargetKind{{APIVersion: "apps/v1", Kind: "Deployment"}},
+			Selector: &metav1.LabelSelector{},
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetSelector"))
+	})
+
+	It("should reject an incomplete targetSelector", func() {
+		obj.Spec.TargetRef = nil
+		obj.Spec.TargetSelector = &v1alpha1.TargetSelector{
+			Kinds: []v1alpha1.TargetKind{{APIVersion: "apps/v1"}},
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetSelector.kinds[0].kind", "spec.targetSelector.selector"))
+	})
+
+	It("should only accept the allowed kinds", func() {
+		obj.Spec.TargetRef = &autoscaling.CrossVersionObjectReference{
+			Kind: "Rollout", Name: "test", APIVersion: "argoproj.io/v1alpha1",
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetRef.kind"))
+
+		rollouts := &DynamicVerticalPodAutoscalerValidator{
+			AllowedKinds: []schema.GroupKind{{Group: "argoproj.io", Kind: "Rollout"}},
+		}
+		_, err = rollouts.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should validate the VPA names", func() {
+		obj.Spec.VPAName = "Example_VPA"
+		obj.Spec.VPANameTemplate = "{{ .TargetName }}-vpa"
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.vpaName", "spec.vpaNameTemplate"))
+
+		obj.Spec.VPAName = "example-vpa"
+		obj.Spec.VPANameTemplate = ""
+		_, err = validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should require a defaultVpaSpec for the Default noMatchAction", func() {
+		obj.Spec.NoMatchAction = v1alpha1.NoMatchActionDefault
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.defaultVpaSpec"))
+
+		obj.Spec.DefaultVpaSpec = &v1alpha1.VpaSpec{}
+		_, err = validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject an empty policy list", func() {
+		obj.Spec.Policies = nil
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies"))
+	})
+
+	It("should accept a policy template without local policies", func() {
+		obj.Spec.Policies = nil
+		obj.Spec.PolicyTemplateRef = &v1alpha1.PolicyTemplateReference{Name: "example"}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject conditions that do not compile", func() {
+		oldObj := obj.DeepCopy()
+		obj.Spec.Policies[0].Condition = "target.metadata.name =="
+		_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[0].condition"))
+	})
+
+	It("should reject conditions that cannot return a bool", func() {
+		obj.Spec.Policies[0].Condition = `"true"`
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[0].condition"))
+	})
+
+	It("should reject vpaSpec expressions that do not compile", func() {
+		obj.Spec.Policies[1].VpaSpec.Expressions = &v1alpha1.VpaSpecExpressions{
+			UpdateMode: `"Auto"`,
+			ContainerPolicies: []v1alpha1.ContainerPolicyExpressions{{
+				MaxAllowed: map[corev1.ResourceName]string{corev1.ResourceMemory: "target.spec +"},
+			}},
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf(
+			"spec.policies[1].vpaSpec.expressions.containerPolicies[0].containerName",
+			"spec.policies[1].vpaSpec.expressions.containerPolicies[0].maxAllowed[memory]",
+		))
+	})
+
+	It("should reject negative durations", func() {
+		obj.Spec.Policies[0].MinDwell = &metav1.Duration{Duration: time.Minute}
+		obj.Spec.Policies[1].EnterAfter = &metav1.Duration{Duration: -time.Minute}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[1].enterAfter"))
+	})
+
+	It("should reject invalid schedules", func() {
+		obj.Spec.Policies[0].Schedule = &v1alpha1.PolicySchedule{
+			Cron: "0 2 * * 6", Duration: metav1.Duration{Duration: 4 * time.Hour}, TimeZone: "Europe/Nowhere",
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[0].schedule"))
+	})
+
+	It("should accept policies following a scheduled policy without condition", func() {
+		obj.Spec.Policies[0] = v1alpha1.DynamicVerticalPodAutoscalerPolicy{
+			Schedule: &v1alpha1.PolicySchedule{Cron: "0 2 * * 6", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+		}
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject policies following a catch-all policy", func() {
+		obj.Spec.Policies = append([]v1alpha1.DynamicVerticalPodAutoscalerPolicy{{}}, obj.Spec.Policies...)
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[1]", "spec.policies[2]"))
+	})
+
+	It("should only validate the updates of the spec of objects not being deleted", func() {
+		// An object stored before the kind was removed from the allowed kinds.
+		obj.Spec.TargetRef = &autoscaling.CrossVersionObjectReference{
+			Kind: "Rollout", Name: "test", APIVersion: "argoproj.io/v1alpha1",
+		}
+		oldObj := obj.DeepCopy()
+
+		By("accepting an update of the finalizers")
+		obj.Finalizers = []string{v1alpha1.Finalizer}
+		_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("accepting an update of an object being deleted")
+		obj.DeletionTimestamp = ptr.To(metav1.Now())
+		obj.Spec.VPAName = "test-vpa"
+		_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("rejecting an update of the spec")
+		obj.DeletionTimestamp = nil
+		_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(causes(err)).To(ConsistOf("spec.targetRef.kind"))
+	})
+})
@@ -0,0 +1,107 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-autoscaling-stackrox-io-v1alpha1-dynamicverticalpodautoscalerpolicytemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=autoscaling.stackrox.io,resources=dynamicverticalpodautoscalerpolicytemplates,verbs=create;update,versions=v1alpha1,name=vdynamicverticalpodautoscalerpolicytemplate.kb.io,admissionReviewVersions=v1
+
+// DynamicVerticalPodAutoscalerPolicyTemplateValidator validates the policies of
+// DynamicVerticalPodAutoscalerPolicyTemplate objects before they are stored, so that an error
+// in a shared template is not discovered by the reconciliation of every object referencing it.
+type DynamicVerticalPodAutoscalerPolicyTemplateValidator struct{}
+
+var _ webhook.CustomValidator = &DynamicVerticalPodAutoscalerPolicyTemplateValidator{}
+
+// SetupWebhookWithManager registers the validating webhook with the Manager.
+func (v *DynamicVerticalPodAutoscalerPolicyTemplateValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *DynamicVerticalPodAutoscalerPolicyTemplateValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator
+func (v *DynamicVerticalPodAutoscalerPolicyTemplateValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldTyped, oldOK := oldObj.(*v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate)
+	newTyped, newOK := newObj.(*v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate)
+	if oldOK && newOK && !updateValidated(newTyped, oldTyped.Spec, newTyped.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *DynamicVerticalPodAutoscalerPolicyTemplateValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *DynamicVerticalPodAutoscalerPolicyTemplateValidator) validate(o runtime.Object) error {
+	obj, ok := o.(*v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate)
+	if !ok {
+		return fmt.Errorf("expected a DynamicVerticalPodAutoscalerPolicyTemplate but got %T", o)
+	}
+
+	errs := validateTemplatePolicies(obj.Spec.Policies, obj.Spec.Language)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("DynamicVerticalPodAutoscalerPolicyTemplate").GroupKind(), obj.Name, errs)
+}
+
+// validateTemplatePolicies checks the policies of a template with validatePolicies. The policies without
+// language are evaluated in the language of the referencing objects when the template has none,
+// so they are only rejected when they are invalid in every supported language.
+func validateTemplatePolicies(policies []v1alpha1.DynamicVerticalPodAutoscalerPolicy, language v1alpha1.ConditionLanguage) field.ErrorList {
+	if len(language) > 0 {
+		return validatePolicies(policies, language)
+	}
+
+	errs := validatePolicies(policies, v1alpha1.ConditionLanguageExpr)
+	if len(errs) == 0 {
+		return nil
+	}
+	celFields := sets.New[string]()
+	for _, err := range validatePolicies(policies, v1alpha1.ConditionLanguageCEL) {
+		celFields.Insert(err.Field)
+	}
+	var invalid field.ErrorList
+	for _, err := range errs {
+		if celFields.Has(err.Field) {
+			invalid = append(invalid, err)
+		}
+	}
+	return invalid
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("DynamicVerticalPodAutoscalerPolicyTemplate Webhook", func() {
+	ctx := context.Background()
+	validator := &DynamicVerticalPodAutoscalerPolicyTemplateValidator{}
+
+	var obj *v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate
+
+	BeforeEach(func() {
+		obj = &v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplateSpec{
+				Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{
+					{Condition: `target.metadata.name == "test"`},
+					{},
+				},
+			},
+		}
+	})
+
+	// causes returns the field paths of the causes of a validation error.
+	causes := func(err error) []string {
+		statusErr, ok := err.(*apierrors.StatusError)
+		Expect(ok).To(BeTrue())
+		var fields []string
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		return fields
+	}
+
+	It("should accept a valid template", func() {
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject conditions that do not compile", func() {
+		obj.Spec.Policies[0].Condition = "target.metadata.name =="
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[0].condition"))
+	})
+
+	It("should reject conditions that are invalid in the language of the template", func() {
+		oldObj := obj.DeepCopy()
+		obj.Spec.Language = v1alpha1.ConditionLanguageCEL
+		obj.Spec.Policies[0].Condition = `target.metadata.annotations?.["vpa-disabled"] == "true" ?? false`
+		_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[0].condition"))
+	})
+
+	It("should accept conditions that are valid in a language of the referencing objects", func() {
+		obj.Spec.Policies[0].Condition = `target.metadata.annotations?.["vpa-disabled"] == "true" ?? false`
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject unreachable policies", func() {
+		obj.Spec.Policies = append(obj.Spec.Policies, v1alpha1.DynamicVerticalPodAutoscalerPolicy{})
+		_, err := validator.ValidateCreate(ctx, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[2]"))
+	})
+
+	It("should only validate the updates of the spec of templates not being deleted", func() {
+		obj.Spec.Policies[0].Condition = "target.metadata.name =="
+		oldObj := obj.DeepCopy()
+
+		obj.Labels = map[string]string{"team": "example"}
+		_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		obj.DeletionTimestamp = ptr.To(metav1.Now())
+		obj.Spec.Language = v1alpha1.ConditionLanguageCEL
+		_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		obj.DeletionTimestamp = nil
+		_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+		Expect(causes(err)).To(ConsistOf("spec.policies[0].condition"))
+	})
+})
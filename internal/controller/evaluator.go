@@ -0,0 +1,257 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"github.com/google/cel-go/interpreter"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// evaluator compiles policy conditions and expressions written in a given language.
+type evaluator interface {
+	// compile compiles condition against the variables of env.
+	// It fails if the condition cannot return a bool.
+	compile(condition string, env map[string]interface{}) (program, error)
+
+	// compileValue compiles an expression computing a field against the variables of env.
+	compileValue(expression string, env map[string]interface{}) (valueProgram, error)
+}
+
+// program is a compiled policy condition.
+type program interface {
+	// run evaluates the condition against env.
+	run(env map[string]interface{}) (bool, error)
+}
+
+// valueProgram is a compiled expression computing a field.
+type valueProgram interface {
+	// eval evaluates the expression against env.
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+// evaluators holds the evaluator of each supported condition language.
+var evaluators = map[v1alpha1.ConditionLanguage]evaluator{
+	v1alpha1.ConditionLanguageExpr: exprEvaluator{},
+	v1alpha1.ConditionLanguageCEL:  celEvaluator{},
+}
+
+// conditionLanguage returns the language of the condition of a policy.
+// The language of the policy takes precedence over the language of the object, objLanguage.
+func conditionLanguage(objLanguage v1alpha1.ConditionLanguage, policy *v1alpha1.DynamicVerticalPodAutoscalerPolicy) v1alpha1.ConditionLanguage {
+	if len(policy.Language) > 0 {
+		return policy.Language
+	}
+	if len(objLanguage) > 0 {
+		return objLanguage
+	}
+	return v1alpha1.ConditionLanguageExpr
+}
+
+// compileCondition compiles a policy condition written in language against env.
+func compileCondition(language v1alpha1.ConditionLanguage, condition string, env map[string]interface{}) (program, error) {
+	e, ok := evaluators[language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported condition language %q", language)
+	}
+	return e.compile(condition, env)
+}
+
+// compileValue compiles an expression computing a field, written in language, against env.
+func compileValue(language v1alpha1.ConditionLanguage, expression string, env map[string]interface{}) (valueProgram, error) {
+	e, ok := evaluators[language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported condition language %q", language)
+	}
+	return e.compileValue(expression, env)
+}
+
+// exprEvaluator evaluates conditions written with https://expr-lang.org
+type exprEvaluator struct{}
+
+func (exprEvaluator) compile(condition string, env map[string]interface{}) (program, error) {
+	p, err := expr.Compile(condition, expr.Env(env), expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+	return exprProgram{program: p}, nil
+}
+
+func (exprEvaluator) compileValue(expression string, env map[string]interface{}) (valueProgram, error) {
+	p, err := expr.Compile(expression, expr.Env(env))
+	if err != nil {
+		return nil, err
+	}
+	return exprProgram{program: p}, nil
+}
+
+type exprProgram struct {
+	program *vm.Program
+}
+
+func (p exprProgram) run(env map[string]interface{}) (bool, error) {
+	output, err := expr.Run(p.program, env)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := output.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition returned %T, expected bool", output)
+	}
+	return matched, nil
+}
+
+func (p exprProgram) eval(env map[string]interface{}) (interface{}, error) {
+	return expr.Run(p.program, env)
+}
+
+// celEvaluator evaluates conditions written with https://github.com/google/cel-spec
+// The variables of the environment are declared with a dynamic type, and behave like
+// their expr counterparts. The now() function returns the current time, and the
+// history helpers are declared by historyCELOptions.
+type celEvaluator struct{}
+
+func (e celEvaluator) compile(condition string, env map[string]interface{}) (program, error) {
+	celEnv, ast, err := e.parse(condition, env)
+	if err != nil {
+		return nil, err
+	}
+	if !ast.OutputType().IsAssignableType(cel.BoolType) {
+		return nil, fmt.Errorf("condition returns %s, expected bool", ast.OutputType())
+	}
+	p, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return celProgram{program: p}, nil
+}
+
+func (e celEvaluator) compileValue(expression string, env map[string]interface{}) (valueProgram, error) {
+	celEnv, ast, err := e.parse(expression, env)
+	if err != nil {
+		return nil, err
+	}
+	p, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return celProgram{program: p}, nil
+}
+
+// parse checks an expression against the variables of env.
+func (celEvaluator) parse(expression string, env map[string]interface{}) (*cel.Env, *cel.Ast, error) {
+	names := make([]string, 0, len(env))
+	for name, value := range env {
+		// The helper functions of the environment are declared as CEL functions.
+		if reflect.ValueOf(value).Kind() == reflect.Func {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	opts := []cel.EnvOption{
+		ext.Strings(),
+		cel.Function("now",
+			cel.Overload("now", []*cel.Type{}, cel.TimestampType,
+				cel.FunctionBinding(func(...ref.Val) ref.Val {
+					return types.Timestamp{Time: time.Now()}
+				}),
+			),
+		),
+	}
+	opts = append(opts, historyCELOptions()...)
+	opts = append(opts, windowCELOptions()...)
+	opts = append(opts, helperCELOptions()...)
+	for _, name := range names {
+		if alias, ok := celAliases[name]; ok {
+			name = alias
+		}
+		opts = append(opts, cel.Variable(name, cel.DynType))
+	}
+
+	celEnv, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	ast, issues := celEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, nil, issues.Err()
+	}
+	return celEnv, ast, nil
+}
+
+// celAliases renames the variables of the environment whose name is a reserved word in CEL.
+var celAliases = map[string]string{"namespace": "ns"}
+
+// celActivation resolves the variables of an environment in CEL programs, including their aliases.
+type celActivation map[string]interface{}
+
+func (a celActivation) ResolveName(name string) (interface{}, bool) {
+	for envName, alias := range celAliases {
+		if alias == name {
+			name = envName
+			break
+		}
+	}
+	value, ok := a[name]
+	return value, ok
+}
+
+func (a celActivation) Parent() interpreter.Activation {
+	return nil
+}
+
+type celProgram struct {
+	program cel.Program
+}
+
+func (p celProgram) run(env map[string]interface{}) (bool, error) {
+	output, _, err := p.program.Eval(celActivation(env))
+	if err != nil {
+		return false, err
+	}
+	matched, ok := output.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("condition returned %s, expected bool", output.Type().TypeName())
+	}
+	return matched, nil
+}
+
+func (p celProgram) eval(env map[string]interface{}) (interface{}, error) {
+	output, _, err := p.program.Eval(celActivation(env))
+	if err != nil {
+		return nil, err
+	}
+	value, err := output.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, err
+	}
+	return value.(*structpb.Value).AsInterface(), nil
+}
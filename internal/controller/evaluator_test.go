@@ -0,0 +1,82 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Evaluators", func() {
+	env := programEnv(
+		map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":              "example",
+				"annotations":       map[string]interface{}{"vpa-disabled": "true"},
+				"creationTimestamp": "2024-01-01T00:00:00Z",
+			},
+		},
+		map[string]interface{}{},
+		map[string]interface{}{"spec": map[string]interface{}{}},
+	)
+
+	DescribeTable("should evaluate conditions",
+		func(language v1alpha1.ConditionLanguage, condition string, want bool) {
+			p, err := compileCondition(language, condition, env)
+			Expect(err).NotTo(HaveOccurred())
+			got, err := p.run(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal(want))
+		},
+		Entry("expr", v1alpha1.ConditionLanguageExpr,
+			`target.metadata.annotations?.["vpa-disabled"] == "true" ?? false`, true),
+		Entry("cel", v1alpha1.ConditionLanguageCEL,
+			`has(target.metadata.annotations) && target.metadata.annotations["vpa-disabled"] == "true"`, true),
+		Entry("cel with now()", v1alpha1.ConditionLanguageCEL,
+			`now() - timestamp(target.metadata.creationTimestamp) < duration("2h")`, false),
+	)
+
+	DescribeTable("should reject conditions that cannot return a bool",
+		func(language v1alpha1.ConditionLanguage, condition string) {
+			_, err := compileCondition(language, condition, env)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("expr", v1alpha1.ConditionLanguageExpr, `"true"`),
+		Entry("cel", v1alpha1.ConditionLanguageCEL, `"true"`),
+	)
+
+	It("should report runtime errors", func() {
+		p, err := compileCondition(v1alpha1.ConditionLanguageCEL, `target.spec.replicas > 1`, env)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = p.run(env)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should prefer the language of the policy", func() {
+		obj := &v1alpha1.DynamicVerticalPodAutoscaler{
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{Language: v1alpha1.ConditionLanguageCEL},
+		}
+		Expect(conditionLanguage(obj.Spec.Language, &v1alpha1.DynamicVerticalPodAutoscalerPolicy{})).
+			To(Equal(v1alpha1.ConditionLanguageCEL))
+		Expect(conditionLanguage(obj.Spec.Language, &v1alpha1.DynamicVerticalPodAutoscalerPolicy{Language: v1alpha1.ConditionLanguageExpr})).
+			To(Equal(v1alpha1.ConditionLanguageExpr))
+		Expect(conditionLanguage("", &v1alpha1.DynamicVerticalPodAutoscalerPolicy{})).
+			To(Equal(v1alpha1.ConditionLanguageExpr))
+	})
+})
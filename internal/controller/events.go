@@ -0,0 +1,106 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// eventRecorderName is the source of the events emitted by the controllers.
+const eventRecorderName = "dynamic-vpa-controller"
+
+// fieldManager is the field manager owning the fields of the VerticalPodAutoscalers applied by the controllers.
+const fieldManager = "dynamic-vpa-controller"
+
+// legacyFieldManager is the field manager of the VerticalPodAutoscalers written with updates by previous
+// versions of the controllers, named by the API server after the binary of the controller.
+const legacyFieldManager = "manager"
+
+// Event reasons, in addition to the condition reasons.
+const (
+	reasonPolicyChanged  = "PolicyChanged"
+	reasonVPACRDNotFound = "VPACRDNotFound"
+	reasonVPACreated     = "VPACreated"
+	reasonVPAUpdated     = "VPAUpdated"
+	reasonVPAAdopted     = "VPAAdopted"
+	reasonVPAOrphaned    = "VPAOrphaned"
+	reasonVPADeleted     = "VPADeleted"
+)
+
+// recordEvent emits an event on obj, if there is a recorder.
+func recordEvent(recorder record.EventRecorder, obj runtime.Object, eventType, reason, message string) {
+	if recorder == nil {
+		return
+	}
+	recorder.Event(obj, eventType, reason, message)
+}
+
+// event emits an event on obj.
+func (t *targetReconciler) event(obj runtime.Object, eventType, reason, message string) {
+	recordEvent(t.recorder, obj, eventType, reason, message)
+}
+
+// targetEvent emits an event about a target on both src and the target, if it exists,
+// so that it is visible when describing either of them.
+func (t *targetReconciler) targetEvent(src *policySource, target *unstructured.Unstructured, eventType, reason, message string) {
+	if target == nil {
+		t.event(src.obj, eventType, reason, message)
+		return
+	}
+
+	targetName := target.GetName()
+	if len(src.obj.GetNamespace()) == 0 {
+		targetName = target.GetNamespace() + "/" + targetName
+	}
+	t.event(src.obj, eventType, reason, fmt.Sprintf("%s %s: %s", target.GetKind(), targetName, message))
+	t.event(target, eventType, reason, fmt.Sprintf("%s %s: %s", src.kind, src.obj.GetName(), message))
+}
+
+// policyTransitionMessage describes the switch from the policy at lastMatched to the policy at matchedIndex,
+// and the resulting change of updateMode from the live VerticalPodAutoscaler.
+func policyTransitionMessage(
+	policies []v1alpha1.DynamicVerticalPodAutoscalerPolicy,
+	lastMatched *int32,
+	matchedIndex int,
+	liveVPA *vpa.VerticalPodAutoscaler,
+	wantVpaSpec *vpa.VerticalPodAutoscalerSpec,
+) string {
+	from := "none"
+	if lastMatched != nil {
+		from = fmt.Sprintf("%d", *lastMatched)
+		if index := int(*lastMatched); index < len(policies) {
+			from = policyDisplayName(index, &policies[index])
+		}
+	}
+	message := fmt.Sprintf("policy %s -> %s", from, policyDisplayName(matchedIndex, &policies[matchedIndex]))
+	if wantVpaSpec == nil {
+		return message + ", skipping reconciliation"
+	}
+
+	fromMode := "none"
+	if liveVPA != nil {
+		fromMode = string(effectiveUpdateMode(&liveVPA.Spec))
+	}
+	return fmt.Sprintf("%s, updateMode %s -> %s", message, fromMode, effectiveUpdateMode(wantVpaSpec))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Events", func() {
+	policies := []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{Name: "warm-up"}, {}, {Skip: true}}
+	live := &vpa.VerticalPodAutoscaler{
+		Spec: vpa.VerticalPodAutoscalerSpec{UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff}},
+	}
+
+	It("should describe policy transitions", func() {
+		Expect(policyTransitionMessage(policies, ptr.To(int32(0)), 1, live, &vpa.VerticalPodAutoscalerSpec{})).
+			To(Equal("policy 0 (warm-up) -> 1, updateMode Off -> Auto"))
+		Expect(policyTransitionMessage(policies, nil, 0, nil, &live.Spec)).
+			To(Equal("policy none -> 0 (warm-up), updateMode none -> Off"))
+		Expect(policyTransitionMessage(policies, ptr.To(int32(1)), 2, live, nil)).
+			To(Equal("policy 1 -> 2, skipping reconciliation"))
+	})
+
+	It("should emit events on both the object and its target", func() {
+		recorder := record.NewFakeRecorder(2)
+		t := &targetReconciler{recorder: recorder}
+		src := namespacedPolicySource(&v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		})
+		target := &unstructured.Unstructured{}
+		target.SetAPIVersion("apps/v1")
+		target.SetKind("Deployment")
+		target.SetName("app")
+		target.SetNamespace("default")
+
+		t.targetEvent(src, target, corev1.EventTypeNormal, reasonPolicyChanged, "policy 0 -> 1")
+		Expect(recorder.Events).To(Receive(Equal("Normal PolicyChanged Deployment app: policy 0 -> 1")))
+		Expect(recorder.Events).To(Receive(Equal("Normal PolicyChanged DynamicVerticalPodAutoscaler example: policy 0 -> 1")))
+	})
+
+	It("should not emit events without a recorder", func() {
+		t := &targetReconciler{}
+		t.event(&v1alpha1.DynamicVerticalPodAutoscaler{}, corev1.EventTypeWarning, v1alpha1.ReasonNoMatch, "")
+	})
+})
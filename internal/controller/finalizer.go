@@ -0,0 +1,142 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// deletionPredicate accepts the updates marking an object for deletion, which may leave its generation unchanged.
+var deletionPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !e.ObjectNew.GetDeletionTimestamp().IsZero()
+	},
+}
+
+// ensureFinalizer adds the finalizer to obj if it is missing, so that its deletionPolicy is applied
+// before it is deleted.
+func ensureFinalizer(ctx context.Context, c client.Client, obj client.Object) error {
+	if !controllerutil.AddFinalizer(obj, v1alpha1.Finalizer) {
+		return nil
+	}
+	return c.Update(ctx, obj)
+}
+
+// deletionPolicyOrDefault returns the deletion policy, defaulted to Delete.
+func deletionPolicyOrDefault(policy v1alpha1.DeletionPolicy) v1alpha1.DeletionPolicy {
+	if len(policy) == 0 {
+		return v1alpha1.DeletionPolicyDelete
+	}
+	return policy
+}
+
+// finalize applies the deletionPolicy of src, which is being deleted, to the VerticalPodAutoscalers it controls,
+// then removes its finalizer. With the Delete policy, the VerticalPodAutoscalers are left to the garbage collector.
+// In DryRun mode, the VerticalPodAutoscalers are not modified, and the changes are only reported in events.
+func (t *targetReconciler) finalize(ctx context.Context, src *policySource) error {
+	if !controllerutil.ContainsFinalizer(src.obj, v1alpha1.Finalizer) {
+		return nil
+	}
+	if policy := deletionPolicyOrDefault(src.deletionPolicy); policy != v1alpha1.DeletionPolicyDelete {
+		if err := t.orphanVPAs(ctx, src, policy); err != nil {
+			return err
+		}
+	}
+	controllerutil.RemoveFinalizer(src.obj, v1alpha1.Finalizer)
+	return t.Update(ctx, src.obj)
+}
+
+// orphanVPAs releases the VerticalPodAutoscalers controlled by src according to policy,
+// so that they are not garbage collected with src.
+func (t *targetReconciler) orphanVPAs(ctx context.Context, src *policySource, policy v1alpha1.DeletionPolicy) error {
+	var list vpa.VerticalPodAutoscalerList
+	if err := t.List(ctx, &list,
+		client.InNamespace(src.obj.GetNamespace()),
+		client.MatchingLabels{src.labelKey: src.obj.GetName()},
+	); err != nil {
+		if meta.IsNoMatchError(err) {
+			// No VerticalPodAutoscaler can exist without its CRD.
+			return nil
+		}
+		return err
+	}
+
+	for i := range list.Items {
+		item := &list.Items[i]
+		if !metav1.IsControlledBy(item, src.obj) {
+			continue
+		}
+		if src.dryRun {
+			action := "orphan"
+			if policy == v1alpha1.DeletionPolicyResetToOff {
+				action = "reset to updateMode Off and orphan"
+			}
+			t.event(src.obj, corev1.EventTypeNormal, v1alpha1.ReasonDryRun,
+				fmt.Sprintf("would %s VerticalPodAutoscaler %s/%s", action, item.Namespace, item.Name))
+			continue
+		}
+		log.FromContext(ctx).Info("Orphaning VerticalPodAutoscaler",
+			"vpa", item.Name, "namespace", item.Namespace, "deletionPolicy", policy)
+		patch := client.MergeFromWithOptions(item.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		orphan(src, item, policy)
+		if err := t.Patch(ctx, item, patch); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		deleteUpdateMode(src.obj, client.ObjectKeyFromObject(item))
+
+		message := fmt.Sprintf("VerticalPodAutoscaler %s/%s orphaned", item.Namespace, item.Name)
+		if policy == v1alpha1.DeletionPolicyResetToOff {
+			message = fmt.Sprintf("VerticalPodAutoscaler %s/%s reset to updateMode Off and orphaned", item.Namespace, item.Name)
+		}
+		t.event(src.obj, corev1.EventTypeNormal, reasonVPAOrphaned, message)
+	}
+	return nil
+}
+
+// orphan removes the controller reference and the label of src from existingVpa,
+// and sets its updateMode to Off first when policy is ResetToOff.
+func orphan(src *policySource, existingVpa *vpa.VerticalPodAutoscaler, policy v1alpha1.DeletionPolicy) {
+	var refs []metav1.OwnerReference
+	for _, ref := range existingVpa.OwnerReferences {
+		if ref.UID != src.obj.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+	existingVpa.OwnerReferences = refs
+	delete(existingVpa.Labels, src.labelKey)
+
+	if policy == v1alpha1.DeletionPolicyResetToOff {
+		updateModeOff := vpa.UpdateModeOff
+		if existingVpa.Spec.UpdatePolicy == nil {
+			existingVpa.Spec.UpdatePolicy = &vpa.PodUpdatePolicy{}
+		}
+		existingVpa.Spec.UpdatePolicy.UpdateMode = &updateModeOff
+	}
+}
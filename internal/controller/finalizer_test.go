@@ -0,0 +1,83 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Finalizer", func() {
+	var (
+		src   *policySource
+		owned *vpa.VerticalPodAutoscaler
+	)
+
+	BeforeEach(func() {
+		src = namespacedPolicySource(&v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default", UID: "dvpa"},
+		})
+		updateModeAuto := vpa.UpdateModeAuto
+		owned = &vpa.VerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+				Labels:    map[string]string{v1alpha1.DynamicVerticalPodAutoscalerLabel: "example", "team": "a"},
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "v1", Kind: "ConfigMap", Name: "config", UID: "config"},
+					{
+						APIVersion: v1alpha1.GroupVersion.String(),
+						Kind:       "DynamicVerticalPodAutoscaler",
+						Name:       "example",
+						UID:        "dvpa",
+						Controller: ptr.To(true),
+					},
+				},
+			},
+			Spec: vpa.VerticalPodAutoscalerSpec{UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeAuto}},
+		}
+	})
+
+	It("should default the deletionPolicy to Delete", func() {
+		Expect(deletionPolicyOrDefault("")).To(Equal(v1alpha1.DeletionPolicyDelete))
+		Expect(deletionPolicyOrDefault(v1alpha1.DeletionPolicyOrphan)).To(Equal(v1alpha1.DeletionPolicyOrphan))
+	})
+
+	It("should only remove the owner reference and the label when orphaning", func() {
+		orphan(src, owned, v1alpha1.DeletionPolicyOrphan)
+
+		Expect(metav1.GetControllerOf(owned)).To(BeNil())
+		Expect(owned.OwnerReferences).To(HaveLen(1))
+		Expect(owned.Labels).To(Equal(map[string]string{"team": "a"}))
+		Expect(*owned.Spec.UpdatePolicy.UpdateMode).To(Equal(vpa.UpdateModeAuto))
+	})
+
+	It("should set the updateMode to Off with ResetToOff", func() {
+		orphan(src, owned, v1alpha1.DeletionPolicyResetToOff)
+		Expect(metav1.GetControllerOf(owned)).To(BeNil())
+		Expect(*owned.Spec.UpdatePolicy.UpdateMode).To(Equal(vpa.UpdateModeOff))
+
+		owned.Spec.UpdatePolicy = nil
+		orphan(src, owned, v1alpha1.DeletionPolicyResetToOff)
+		Expect(*owned.Spec.UpdatePolicy.UpdateMode).To(Equal(vpa.UpdateModeOff))
+	})
+})
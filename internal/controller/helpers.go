@@ -0,0 +1,440 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// helperFunc is a function of the library available in the conditions and the computed fields.
+type helperFunc struct {
+	name string
+	// variables are the variables of the environment passed as first arguments to fn, if any,
+	// e.g. replicas() reads the target.
+	variables []string
+	// arities are the accepted numbers of arguments, excluding the variables.
+	arities []int
+	fn      func(args []interface{}) (interface{}, error)
+}
+
+// helperFuncs is the library of functions available in both languages.
+var helperFuncs = []helperFunc{
+	{
+		// quantity(value) returns a quantity, e.g. "512Mi" or "100m", as a number in the base unit of the resource,
+		// so that quantities can be compared and combined with arithmetic operators.
+		name:    "quantity",
+		arities: []int{1},
+		fn: func(args []interface{}) (interface{}, error) {
+			q, err := parseQuantity(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return q.AsApproximateFloat64(), nil
+		},
+	},
+	{
+		// cpuMillis(value) returns a CPU quantity in millicores, e.g. 500 for "0.5".
+		name:    "cpuMillis",
+		arities: []int{1},
+		fn: func(args []interface{}) (interface{}, error) {
+			q, err := parseQuantity(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return q.MilliValue(), nil
+		},
+	},
+	{
+		// memBytes(value) returns a memory quantity in bytes, e.g. 536870912 for "512Mi".
+		name:    "memBytes",
+		arities: []int{1},
+		fn: func(args []interface{}) (interface{}, error) {
+			q, err := parseQuantity(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return q.Value(), nil
+		},
+	},
+	{
+		// container(name) returns the container of the pod template of the target, or nil.
+		name:      "container",
+		variables: []string{"target"},
+		arities:   []int{1},
+		fn: func(args []interface{}) (interface{}, error) {
+			name, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if container := templateContainer(mapArg(args[0]), name); container != nil {
+				return container, nil
+			}
+			return nil, nil
+		},
+	},
+	{
+		// hasLabel(key) or hasLabel(key, value) returns whether the target has the label, with the value if given.
+		name:      "hasLabel",
+		variables: []string{"target"},
+		arities:   []int{1, 2},
+		fn: func(args []interface{}) (interface{}, error) {
+			key, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			value, found, _ := unstructured.NestedString(mapArg(args[0]), "metadata", "labels", key)
+			if len(args) == 2 {
+				return found, nil
+			}
+			want, err := stringArg(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			return found && value == want, nil
+		},
+	},
+	{
+		// annotation(key, default) returns the annotation of the target, or default if it is not set.
+		name:      "annotation",
+		variables: []string{"target"},
+		arities:   []int{2},
+		fn: func(args []interface{}) (interface{}, error) {
+			key, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			value, found, _ := unstructured.NestedString(mapArg(args[0]), "metadata", "annotations", key)
+			if !found {
+				return args[2], nil
+			}
+			return value, nil
+		},
+	},
+	{
+		// replicas() returns the desired number of replicas of the target.
+		name:      "replicas",
+		variables: []string{"target"},
+		arities:   []int{0},
+		fn: func(args []interface{}) (interface{}, error) {
+			return targetReplicas(mapArg(args[0])), nil
+		},
+	},
+	{
+		// recommendation(container, resource) or recommendation(container, resource, bound) returns the
+		// recommendation of the VPA for a container, as a number in the base unit of the resource, or nil.
+		// The bound is target (default), lowerBound, upperBound or uncappedTarget.
+		name:      "recommendation",
+		variables: []string{"vpa"},
+		arities:   []int{2, 3},
+		fn: func(args []interface{}) (interface{}, error) {
+			container, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			resourceName, err := stringArg(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			bound := "target"
+			if len(args) == 4 {
+				if bound, err = stringArg(args, 3); err != nil {
+					return nil, err
+				}
+			}
+			return vpaRecommendation(mapArg(args[0]), container, resourceName, bound)
+		},
+	},
+	{
+		// recommendationDelta(container, resource) returns the relative difference between the target recommendation
+		// of the VPA and the request of a container of the target, e.g. 0.25 when the VPA recommends 25% more,
+		// or nil if either is missing.
+		name:      "recommendationDelta",
+		variables: []string{"target", "vpa"},
+		arities:   []int{2},
+		fn: func(args []interface{}) (interface{}, error) {
+			container, err := stringArg(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			resourceName, err := stringArg(args, 3)
+			if err != nil {
+				return nil, err
+			}
+			delta, found, err := recommendationDelta(mapArg(args[0]), mapArg(args[1]), container, resourceName)
+			if err != nil || !found {
+				return nil, err
+			}
+			return delta, nil
+		},
+	},
+	{
+		// maxRecommendationDelta() returns the largest absolute recommendationDelta of all the containers
+		// and resources of the target, or 0 if there is no recommendation.
+		name:      "maxRecommendationDelta",
+		variables: []string{"target", "vpa"},
+		arities:   []int{0},
+		fn: func(args []interface{}) (interface{}, error) {
+			return maxRecommendationDelta(mapArg(args[0]), mapArg(args[1]))
+		},
+	},
+	{
+		// recommendationSpread(container, resource) returns the ratio between the upper and the lower bound of the
+		// recommendation of the VPA for a container, e.g. 1.5, or nil if either is missing or the lower bound is 0.
+		name:      "recommendationSpread",
+		variables: []string{"vpa"},
+		arities:   []int{2},
+		fn: func(args []interface{}) (interface{}, error) {
+			container, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			resourceName, err := stringArg(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			lower, err := vpaRecommendation(mapArg(args[0]), container, resourceName, "lowerBound")
+			if err != nil {
+				return nil, err
+			}
+			upper, err := vpaRecommendation(mapArg(args[0]), container, resourceName, "upperBound")
+			if err != nil {
+				return nil, err
+			}
+			if lower == nil || upper == nil || lower.(float64) == 0 {
+				return nil, nil
+			}
+			return upper.(float64) / lower.(float64), nil
+		},
+	},
+}
+
+// setHelpers sets the functions of helperFuncs in env, for expr programs.
+// The variables are read from env when the functions are called.
+func setHelpers(env map[string]interface{}) {
+	for _, helper := range helperFuncs {
+		helper := helper
+		env[helper.name] = func(args ...interface{}) (interface{}, error) {
+			if !helper.accepts(len(args)) {
+				return nil, fmt.Errorf("%s: unexpected number of arguments %d", helper.name, len(args))
+			}
+			values := make([]interface{}, 0, len(helper.variables)+len(args))
+			for _, variable := range helper.variables {
+				values = append(values, env[variable])
+			}
+			args = append(values, args...)
+			return helper.fn(args)
+		}
+	}
+}
+
+// accepts returns whether the helper accepts the given number of arguments.
+func (h *helperFunc) accepts(arity int) bool {
+	for _, accepted := range h.arities {
+		if arity == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// helperCELOptions declares the functions of helperFuncs in CEL. The functions reading variables
+// are declared with macros passing the variables, e.g. replicas() is expanded to replicas(target).
+func helperCELOptions() []cel.EnvOption {
+	var opts []cel.EnvOption
+	for _, helper := range helperFuncs {
+		helper := helper
+		binding := cel.FunctionBinding(func(values ...ref.Val) ref.Val {
+			args := make([]interface{}, 0, len(values))
+			for _, value := range values {
+				args = append(args, value.Value())
+			}
+			result, err := helper.fn(args)
+			if err != nil {
+				return types.NewErr("%s: %v", helper.name, err)
+			}
+			return types.DefaultTypeAdapter.NativeToValue(result)
+		})
+
+		var overloads []cel.FunctionOpt
+		for _, arity := range helper.arities {
+			params := make([]*cel.Type, len(helper.variables)+arity)
+			if len(helper.variables) > 0 {
+				opts = append(opts, cel.Macros(cel.NewGlobalMacro(helper.name, arity,
+					func(eh cel.MacroExprHelper, _ *exprpb.Expr, args []*exprpb.Expr) (*exprpb.Expr, *common.Error) {
+						values := make([]*exprpb.Expr, 0, len(helper.variables)+len(args))
+						for _, variable := range helper.variables {
+							values = append(values, eh.Ident(variable))
+						}
+						return eh.GlobalCall(helper.name, append(values, args...)...), nil
+					})))
+			}
+			for i := range params {
+				params[i] = cel.DynType
+			}
+			overloads = append(overloads, cel.Overload(fmt.Sprintf("%s_%d", helper.name, len(params)), params, cel.DynType, binding))
+		}
+		opts = append(opts, cel.Function(helper.name, overloads...))
+	}
+	return opts
+}
+
+// parseQuantity parses a quantity given as a string or a number in the base unit.
+func parseQuantity(value interface{}) (resource.Quantity, error) {
+	switch v := value.(type) {
+	case string:
+		return resource.ParseQuantity(v)
+	case int:
+		return *resource.NewQuantity(int64(v), resource.DecimalSI), nil
+	case int64:
+		return *resource.NewQuantity(v, resource.DecimalSI), nil
+	case uint64:
+		return *resource.NewQuantity(int64(v), resource.DecimalSI), nil
+	case float64:
+		// Rounded as toQuantity does, so that the helpers and the expressions agree.
+		return *resource.NewMilliQuantity(int64(math.Round(v*1000)), resource.DecimalSI), nil
+	}
+	return resource.Quantity{}, fmt.Errorf("cannot convert %T to a quantity", value)
+}
+
+// stringArg returns the argument at index, which must be a string.
+func stringArg(args []interface{}, index int) (string, error) {
+	value, ok := args[index].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %d must be a string, got %T", index, args[index])
+	}
+	return value, nil
+}
+
+// mapArg returns the object passed as argument, or nil if it is not an object.
+func mapArg(arg interface{}) map[string]interface{} {
+	obj, _ := arg.(map[string]interface{})
+	return obj
+}
+
+// templateContainer returns the container name of the pod template of target, or nil.
+// The pod template is the normalised podTemplate of the target variable, or spec.template.
+func templateContainer(target map[string]interface{}, name string) map[string]interface{} {
+	template, ok := target["podTemplate"].(map[string]interface{})
+	if !ok {
+		template, _, _ = unstructured.NestedMap(target, "spec", "template")
+	}
+	containers, _, _ := unstructured.NestedSlice(template, "spec", "containers")
+	for _, item := range containers {
+		container, _ := item.(map[string]interface{})
+		if container["name"] == name {
+			return container
+		}
+	}
+	return nil
+}
+
+// targetReplicas returns the desired number of replicas of target: the normalised replicas of the target
+// variable, or spec.replicas, defaulted to 1, or status.desiredNumberScheduled for the workloads without
+// replicas, such as DaemonSets.
+func targetReplicas(target map[string]interface{}) int64 {
+	if replicas, ok := target["replicas"].(int64); ok {
+		return replicas
+	}
+	if replicas, found, _ := unstructured.NestedInt64(target, "spec", "replicas"); found {
+		return replicas
+	}
+	if desired, found, _ := unstructured.NestedInt64(target, "status", "desiredNumberScheduled"); found {
+		return desired
+	}
+	return 1
+}
+
+// vpaRecommendation returns a bound of the recommendation of vpa for a container and a resource,
+// as a number in the base unit of the resource, or nil if there is no such recommendation.
+func vpaRecommendation(vpa map[string]interface{}, container, resourceName, bound string) (interface{}, error) {
+	switch bound {
+	case "target", "lowerBound", "upperBound", "uncappedTarget":
+	default:
+		return nil, fmt.Errorf("unknown bound %q", bound)
+	}
+
+	recommendations, _, _ := unstructured.NestedSlice(vpa, "status", "recommendation", "containerRecommendations")
+	for _, item := range recommendations {
+		recommendation, _ := item.(map[string]interface{})
+		if recommendation["containerName"] != container {
+			continue
+		}
+		value, found, _ := unstructured.NestedFieldNoCopy(recommendation, bound, resourceName)
+		if !found {
+			return nil, nil
+		}
+		q, err := parseQuantity(value)
+		if err != nil {
+			return nil, err
+		}
+		return q.AsApproximateFloat64(), nil
+	}
+	return nil, nil
+}
+
+// recommendationDelta returns the relative difference between the target recommendation of vpa and the request
+// of a container of target, and whether both are set and the request is not 0.
+func recommendationDelta(target, vpa map[string]interface{}, container, resourceName string) (float64, bool, error) {
+	recommended, err := vpaRecommendation(vpa, container, resourceName, "target")
+	if err != nil || recommended == nil {
+		return 0, false, err
+	}
+	value, found, _ := unstructured.NestedFieldNoCopy(templateContainer(target, container), "resources", "requests", resourceName)
+	if !found {
+		return 0, false, nil
+	}
+	request, err := parseQuantity(value)
+	if err != nil {
+		return 0, false, err
+	}
+	if request.IsZero() {
+		return 0, false, nil
+	}
+	return (recommended.(float64) - request.AsApproximateFloat64()) / request.AsApproximateFloat64(), true, nil
+}
+
+// maxRecommendationDelta returns the largest absolute recommendationDelta of the recommendations of vpa
+// for the containers of target.
+func maxRecommendationDelta(target, vpa map[string]interface{}) (float64, error) {
+	var result float64
+	recommendations, _, _ := unstructured.NestedSlice(vpa, "status", "recommendation", "containerRecommendations")
+	for _, item := range recommendations {
+		recommendation, _ := item.(map[string]interface{})
+		container, _ := recommendation["containerName"].(string)
+		bounds, _ := recommendation["target"].(map[string]interface{})
+		for resourceName := range bounds {
+			delta, found, err := recommendationDelta(target, vpa, container, resourceName)
+			if err != nil {
+				return 0, err
+			}
+			if found {
+				result = math.Max(result, math.Abs(delta))
+			}
+		}
+	}
+	return result, nil
+}
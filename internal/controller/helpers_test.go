@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Helper functions", func() {
+	target := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      map[string]interface{}{"tier": "backend"},
+			"annotations": map[string]interface{}{"owner": "shop"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name": "app",
+							"resources": map[string]interface{}{
+								"requests": map[string]interface{}{"cpu": "250m", "memory": "512Mi"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	vpaObj := map[string]interface{}{
+		"status": map[string]interface{}{
+			"recommendation": map[string]interface{}{
+				"containerRecommendations": []interface{}{
+					map[string]interface{}{
+						"containerName": "app",
+						"target":        map[string]interface{}{"cpu": "300m", "memory": "1Gi"},
+						"lowerBound":    map[string]interface{}{"cpu": "200m", "memory": "512Mi"},
+						"upperBound":    map[string]interface{}{"cpu": "1", "memory": "2Gi"},
+					},
+				},
+			},
+		},
+	}
+
+	// run evaluates condition in both languages against target and vpaObj.
+	run := func(language v1alpha1.ConditionLanguage, condition string) (bool, error) {
+		env := programEnv(target, vpaObj, nil)
+		p, err := compileCondition(language, condition, env)
+		if err != nil {
+			return false, err
+		}
+		return p.run(env)
+	}
+
+	DescribeTable("should evaluate the helpers",
+		func(condition string) {
+			for _, language := range []v1alpha1.ConditionLanguage{v1alpha1.ConditionLanguageExpr, v1alpha1.ConditionLanguageCEL} {
+				matched, err := run(language, condition)
+				Expect(err).NotTo(HaveOccurred(), string(language))
+				Expect(matched).To(BeTrue(), string(language))
+			}
+		},
+		Entry("quantity comparison", `quantity("512Mi") > quantity("256Mi") && quantity("100m") == 0.1`),
+		Entry("quantity arithmetic", `quantity("1Gi") - quantity("512Mi") == quantity("512Mi")`),
+		Entry("quantity of a number", `quantity(2) == 2.0`),
+		Entry("quantity of a fractional millicore", `quantity(0.0015) == quantity("2m") && quantity(-0.0015) == quantity("-2m")`),
+		Entry("cpuMillis", `cpuMillis("0.5") == 500 && cpuMillis("250m") == 250`),
+		Entry("memBytes", `memBytes("512Mi") == 536870912 && memBytes("1G") == 1000000000`),
+		Entry("container", `memBytes(container("app").resources.requests.memory) >= memBytes("512Mi")`),
+		Entry("hasLabel", `hasLabel("tier") && hasLabel("tier", "backend") && !hasLabel("tier", "frontend") && !hasLabel("team")`),
+		Entry("annotation", `annotation("owner", "none") == "shop" && annotation("team", "none") == "none"`),
+		Entry("replicas", `replicas() == 3`),
+		Entry("recommendation", `recommendation("app", "memory") == quantity("1Gi") && recommendation("app", "cpu", "upperBound") == 1.0`),
+		Entry("recommendationDelta", `recommendationDelta("app", "memory") == 1.0 && recommendationDelta("app", "cpu") > 0.19 && recommendationDelta("app", "cpu") < 0.21`),
+		Entry("maxRecommendationDelta", `maxRecommendationDelta() == 1.0`),
+		Entry("recommendationSpread", `recommendationSpread("app", "memory") == 4.0 && recommendationSpread("app", "cpu") == 5.0`),
+	)
+
+	It("should return nil for missing containers and recommendations", func() {
+		matched, err := run(v1alpha1.ConditionLanguageExpr, `container("sidecar") == nil && recommendation("sidecar", "cpu") == nil &&
+			recommendationDelta("sidecar", "cpu") == nil && recommendationSpread("sidecar", "cpu") == nil`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matched).To(BeTrue())
+	})
+
+	It("should fail on invalid arguments", func() {
+		_, err := run(v1alpha1.ConditionLanguageExpr, `quantity("lots") > 1`)
+		Expect(err).To(HaveOccurred())
+		_, err = run(v1alpha1.ConditionLanguageCEL, `recommendation("app", "cpu", "median") == 1.0`)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should ignore the containers without requests in the deltas", func() {
+		delta, err := maxRecommendationDelta(map[string]interface{}{}, vpaObj)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(BeZero())
+
+		_, found, err := recommendationDelta(target, vpaObj, "app", "ephemeral-storage")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("should default the replicas", func() {
+		Expect(targetReplicas(map[string]interface{}{})).To(Equal(int64(1)))
+		Expect(targetReplicas(map[string]interface{}{
+			"status": map[string]interface{}{"desiredNumberScheduled": int64(5)},
+		})).To(Equal(int64(5)))
+	})
+})
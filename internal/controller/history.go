@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/utils/ptr"
+
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// DefaultHistorySize is the default maximum number of policy transitions kept in the status.
+const DefaultHistorySize = 10
+
+// lastMatchedIndex returns the index of the policy matched after the last transition of history, if any.
+func lastMatchedIndex(history []v1alpha1.PolicyTransition) *int32 {
+	if len(history) == 0 {
+		return nil
+	}
+	return ptr.To(history[len(history)-1].To)
+}
+
+// recordTransition appends a transition to matchedIndex to history, unless it was already the last matched policy.
+// The oldest transitions are dropped to keep at most size transitions.
+func recordTransition(
+	history []v1alpha1.PolicyTransition,
+	matchedIndex int,
+	specHash string,
+	now time.Time,
+	size int,
+) []v1alpha1.PolicyTransition {
+	from := lastMatchedIndex(history)
+	if from != nil && int(*from) == matchedIndex {
+		return history
+	}
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	history = append(history, v1alpha1.PolicyTransition{
+		Time:     metav1.NewTime(now.In(time.UTC)),
+		From:     from,
+		To:       int32(matchedIndex),
+		SpecHash: specHash,
+	})
+	if len(history) > size {
+		history = append([]v1alpha1.PolicyTransition(nil), history[len(history)-size:]...)
+	}
+	return history
+}
+
+// specHash returns a short hash identifying a VerticalPodAutoscaler spec.
+func specHash(spec *vpa.VerticalPodAutoscalerSpec) string {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return ""
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// setHistory sets the history variable of env, and the helpers reading it.
+// The transitions are exposed as maps with the time, from, to and specHash keys.
+func setHistory(env map[string]interface{}, history []v1alpha1.PolicyTransition) {
+	transitions := make([]interface{}, 0, len(history))
+	for _, transition := range history {
+		var from interface{}
+		if transition.From != nil {
+			from = int(*transition.From)
+		}
+		transitions = append(transitions, map[string]interface{}{
+			"time":     transition.Time.Time,
+			"from":     from,
+			"to":       int(transition.To),
+			"specHash": transition.SpecHash,
+		})
+	}
+
+	env["history"] = transitions
+	// The helpers are only called by expr programs. CEL programs call the functions
+	// declared by historyCELOptions, which receive the history variable as argument.
+	env["timeInCurrentPolicy"] = func() time.Duration {
+		return timeInCurrentPolicy(transitions, time.Now())
+	}
+	env["transitionsSince"] = func(window time.Duration) int {
+		return transitionsSince(transitions, window, time.Now())
+	}
+}
+
+// timeInCurrentPolicy returns the time elapsed since the last transition of history, or 0 if there is none.
+func timeInCurrentPolicy(history []interface{}, now time.Time) time.Duration {
+	if len(history) == 0 {
+		return 0
+	}
+	last, _ := history[len(history)-1].(map[string]interface{})
+	at, _ := last["time"].(time.Time)
+	return now.Sub(at)
+}
+
+// transitionsSince returns the number of changes of policy in history during the last window.
+// The first match of a policy is not a change.
+func transitionsSince(history []interface{}, window time.Duration, now time.Time) int {
+	count := 0
+	for _, item := range history {
+		transition, _ := item.(map[string]interface{})
+		at, _ := transition["time"].(time.Time)
+		if transition["from"] != nil && now.Sub(at) <= window {
+			count++
+		}
+	}
+	return count
+}
+
+// historyCELOptions declares the history helpers in CEL. Their macros pass the history variable
+// to the functions implementing them, e.g. timeInCurrentPolicy() is expanded to timeInCurrentPolicy(history).
+func historyCELOptions() []cel.EnvOption {
+	historyArg := func(function string) cel.MacroExpander {
+		return func(eh cel.MacroExprHelper, _ *exprpb.Expr, args []*exprpb.Expr) (*exprpb.Expr, *common.Error) {
+			return eh.GlobalCall(function, append([]*exprpb.Expr{eh.Ident("history")}, args...)...), nil
+		}
+	}
+	historyValue := func(val ref.Val) []interface{} {
+		history, _ := val.Value().([]interface{})
+		return history
+	}
+
+	return []cel.EnvOption{
+		cel.Macros(
+			cel.NewGlobalMacro("timeInCurrentPolicy", 0, historyArg("timeInCurrentPolicy")),
+			cel.NewGlobalMacro("transitionsSince", 1, historyArg("transitionsSince")),
+		),
+		cel.Function("timeInCurrentPolicy",
+			cel.Overload("timeInCurrentPolicy_dyn", []*cel.Type{cel.DynType}, cel.DurationType,
+				cel.UnaryBinding(func(history ref.Val) ref.Val {
+					return types.Duration{Duration: timeInCurrentPolicy(historyValue(history), time.Now())}
+				}),
+			),
+		),
+		cel.Function("transitionsSince",
+			cel.Overload("transitionsSince_dyn_duration", []*cel.Type{cel.DynType, cel.DurationType}, cel.IntType,
+				cel.BinaryBinding(func(history, window ref.Val) ref.Val {
+					d, ok := window.(types.Duration)
+					if !ok {
+						return types.MaybeNoSuchOverloadErr(window)
+					}
+					return types.Int(transitionsSince(historyValue(history), d.Duration, time.Now()))
+				}),
+			),
+		),
+	}
+}
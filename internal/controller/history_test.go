@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("History", func() {
+	now := time.Now()
+
+	It("should only record changes of policy", func() {
+		history := recordTransition(nil, 1, "a", now, 10)
+		Expect(history).To(HaveLen(1))
+		Expect(history[0].From).To(BeNil())
+
+		Expect(recordTransition(history, 1, "a", now, 10)).To(Equal(history))
+
+		history = recordTransition(history, 0, "b", now, 10)
+		Expect(history).To(HaveLen(2))
+		Expect(history[1].From).To(Equal(ptr.To(int32(1))))
+		Expect(history[1].To).To(Equal(int32(0)))
+	})
+
+	It("should keep the most recent transitions", func() {
+		var history []v1alpha1.PolicyTransition
+		for i := 0; i < 5; i++ {
+			history = recordTransition(history, i, "", now, 3)
+		}
+		Expect(history).To(HaveLen(3))
+		Expect(history[0].To).To(Equal(int32(2)))
+		Expect(*lastMatchedIndex(history)).To(Equal(int32(4)))
+	})
+
+	history := []v1alpha1.PolicyTransition{
+		{Time: metav1.NewTime(now.Add(-3 * time.Hour)), To: 1},
+		{Time: metav1.NewTime(now.Add(-2 * time.Hour)), From: ptr.To(int32(1)), To: 0},
+		{Time: metav1.NewTime(now.Add(-30 * time.Minute)), From: ptr.To(int32(0)), To: 1},
+	}
+
+	DescribeTable("should expose the history to the conditions",
+		func(language v1alpha1.ConditionLanguage, condition string) {
+			env := programEnv(nil, nil, nil)
+			p, err := compileCondition(language, condition, env)
+			Expect(err).NotTo(HaveOccurred())
+
+			setHistory(env, history)
+			matched, err := p.run(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+		},
+		Entry("expr history", v1alpha1.ConditionLanguageExpr, `len(history) == 3 && history[1].from == 1`),
+		Entry("expr timeInCurrentPolicy", v1alpha1.ConditionLanguageExpr,
+			`timeInCurrentPolicy() > duration("29m") && timeInCurrentPolicy() < duration("31m")`),
+		Entry("expr transitionsSince", v1alpha1.ConditionLanguageExpr,
+			`transitionsSince(duration("1h")) == 1 && transitionsSince(duration("4h")) == 2`),
+		Entry("cel history", v1alpha1.ConditionLanguageCEL,
+			`size(history) == 3 && history[1].from == 1 && now() - history[2].time < duration("1h")`),
+		Entry("cel timeInCurrentPolicy", v1alpha1.ConditionLanguageCEL,
+			`timeInCurrentPolicy() > duration("29m") && timeInCurrentPolicy() < duration("31m")`),
+		Entry("cel transitionsSince", v1alpha1.ConditionLanguageCEL,
+			`transitionsSince(duration("1h")) == 1 && transitionsSince(duration("4h")) == 2`),
+	)
+})
@@ -0,0 +1,83 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// holdPolicy returns the index of the policy to apply when the conditions matched the policy at matchedIndex.
+// The policy of the last transition of history is kept until both its minDwell and the enterAfter of the
+// matched policy, counted from the first evaluation reported by pending, have elapsed.
+// The returned pending transition is nil unless the matched policy is held back.
+func holdPolicy(
+	policies []v1alpha1.DynamicVerticalPodAutoscalerPolicy,
+	history []v1alpha1.PolicyTransition,
+	pending *v1alpha1.PendingTransition,
+	matchedIndex int,
+	now time.Time,
+) (int, *v1alpha1.PendingTransition) {
+	current := lastMatchedIndex(history)
+	// The indexes of the history may not match the policies anymore if they were edited.
+	if current == nil || int(*current) == matchedIndex || int(*current) >= len(policies) {
+		return matchedIndex, nil
+	}
+
+	since := now
+	if pending != nil && int(pending.To) == matchedIndex {
+		since = pending.Since.Time
+	}
+	eligibleAt := since
+	if enterAfter := policies[matchedIndex].EnterAfter; enterAfter != nil {
+		eligibleAt = since.Add(enterAfter.Duration)
+	}
+	if minDwell := policies[*current].MinDwell; minDwell != nil {
+		if dwellEnd := history[len(history)-1].Time.Add(minDwell.Duration); dwellEnd.After(eligibleAt) {
+			eligibleAt = dwellEnd
+		}
+	}
+	if !now.Before(eligibleAt) {
+		return matchedIndex, nil
+	}
+
+	return int(*current), &v1alpha1.PendingTransition{
+		To:         int32(matchedIndex),
+		Since:      metav1.NewTime(since.In(time.UTC)),
+		EligibleAt: metav1.NewTime(eligibleAt.In(time.UTC)),
+	}
+}
+
+// pendingResult returns result, requeued when the earliest pending transition of status becomes eligible
+// if it is sooner than the requeue of result, so that the transition is not delayed until the next resync.
+func pendingResult(result ctrl.Result, status *v1alpha1.DynamicVerticalPodAutoscalerStatus, now time.Time) ctrl.Result {
+	pending := []*v1alpha1.PendingTransition{status.PendingTransition}
+	for i := range status.Targets {
+		pending = append(pending, status.Targets[i].PendingTransition)
+	}
+
+	for _, transition := range pending {
+		if transition != nil {
+			result = boundaryResult(result, transition.EligibleAt.Time, now)
+		}
+	}
+	return result
+}
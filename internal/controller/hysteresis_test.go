@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("holdPolicy", func() {
+	now := time.Now()
+	policies := []v1alpha1.DynamicVerticalPodAutoscalerPolicy{
+		{Name: "warm-up", MinDwell: &metav1.Duration{Duration: time.Hour}},
+		{Name: "auto", EnterAfter: &metav1.Duration{Duration: 10 * time.Minute}},
+		{Name: "off"},
+	}
+	// enteredAt returns a history whose last transition is to the policy at index, ago.
+	enteredAt := func(index int32, ago time.Duration) []v1alpha1.PolicyTransition {
+		return []v1alpha1.PolicyTransition{{Time: metav1.NewTime(now.Add(-ago)), To: index}}
+	}
+
+	It("should not delay the first match", func() {
+		index, pending := holdPolicy(policies, nil, nil, 1, now)
+		Expect(index).To(Equal(1))
+		Expect(pending).To(BeNil())
+	})
+
+	It("should keep the current policy until its minDwell has elapsed", func() {
+		index, pending := holdPolicy(policies, enteredAt(0, 20*time.Minute), nil, 2, now)
+		Expect(index).To(Equal(0))
+		Expect(pending.To).To(Equal(int32(2)))
+		Expect(pending.EligibleAt.Time).To(BeTemporally("~", now.Add(40*time.Minute), time.Second))
+
+		index, pending = holdPolicy(policies, enteredAt(0, 2*time.Hour), nil, 2, now)
+		Expect(index).To(Equal(2))
+		Expect(pending).To(BeNil())
+	})
+
+	It("should switch once the condition held for enterAfter", func() {
+		index, pending := holdPolicy(policies, enteredAt(2, time.Hour), nil, 1, now)
+		Expect(index).To(Equal(2))
+		Expect(pending.Since.Time).To(BeTemporally("~", now, time.Second))
+		Expect(pending.EligibleAt.Time).To(BeTemporally("~", now.Add(10*time.Minute), time.Second))
+
+		index, pending = holdPolicy(policies, enteredAt(2, time.Hour), pending, 1, now.Add(10*time.Minute))
+		Expect(index).To(Equal(1))
+		Expect(pending).To(BeNil())
+	})
+
+	It("should restart enterAfter when another policy matched in between", func() {
+		previous := &v1alpha1.PendingTransition{To: 0, Since: metav1.NewTime(now.Add(-time.Hour))}
+		index, pending := holdPolicy(policies, enteredAt(2, time.Hour), previous, 1, now)
+		Expect(index).To(Equal(2))
+		Expect(pending.Since.Time).To(BeTemporally("~", now, time.Second))
+	})
+
+	It("should wait for the latest of minDwell and enterAfter", func() {
+		index, pending := holdPolicy(policies, enteredAt(0, 55*time.Minute), nil, 1, now)
+		Expect(index).To(Equal(0))
+		Expect(pending.EligibleAt.Time).To(BeTemporally("~", now.Add(10*time.Minute), time.Second))
+	})
+
+	It("should requeue when the earliest transition becomes eligible", func() {
+		status := &v1alpha1.DynamicVerticalPodAutoscalerStatus{
+			Targets: []v1alpha1.TargetStatus{
+				{PendingTransition: &v1alpha1.PendingTransition{EligibleAt: metav1.NewTime(now.Add(30 * time.Second))}},
+				{PendingTransition: &v1alpha1.PendingTransition{EligibleAt: metav1.NewTime(now.Add(10 * time.Second))}},
+				{},
+			},
+		}
+		result := pendingResult(ctrl.Result{RequeueAfter: time.Minute}, status, now)
+		Expect(result.RequeueAfter).To(BeNumerically("~", 10*time.Second, time.Second))
+
+		result = pendingResult(ctrl.Result{RequeueAfter: time.Minute}, &v1alpha1.DynamicVerticalPodAutoscalerStatus{}, now)
+		Expect(result.RequeueAfter).To(Equal(time.Minute))
+	})
+})
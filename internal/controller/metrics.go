@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "dynamic_vpa"
+
+// The labels identifying the DynamicVerticalPodAutoscaler or ClusterDynamicVerticalPodAutoscaler of a metric.
+// The namespace is empty for ClusterDynamicVerticalPodAutoscalers.
+var ownerLabelNames = []string{"namespace", "name"}
+
+var (
+	programCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "program_cache_hits_total",
+		Help:      "Number of compiled policy conditions served from the cache.",
+	})
+	programCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "program_cache_misses_total",
+		Help:      "Number of policy conditions compiled because they were not in the cache.",
+	})
+	policyMatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "policy_matches_total",
+		Help:      "Number of evaluations that matched a policy.",
+	}, append(ownerLabelNames, "policy_index", "policy_name"))
+	policyErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "policy_errors_total",
+		Help:      "Number of evaluations that failed, by reason: CompileError, RuntimeError or NoMatch.",
+	}, append(ownerLabelNames, "reason"))
+	expressionEvaluationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "expression_evaluation_duration_seconds",
+		Help:      "Latency of the evaluation of compiled conditions and expressions.",
+		Buckets:   prometheus.ExponentialBuckets(1e-6, 4, 10),
+	}, []string{"language"})
+	vpaOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "vpa_operations_total",
+		Help:      "Number of VerticalPodAutoscalers created, updated, adopted or deleted, by operation.",
+	}, append(ownerLabelNames, "operation"))
+	vpaUpdateMode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "vpa_update_mode",
+		Help:      "The effective updateMode of every managed VerticalPodAutoscaler, set to 1 for the current mode.",
+	}, append(ownerLabelNames, "vpa", "update_mode"))
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		programCacheHits,
+		programCacheMisses,
+		policyMatches,
+		policyErrors,
+		expressionEvaluationDuration,
+		vpaOperations,
+		vpaUpdateMode,
+	)
+}
+
+// ownerLabels returns the labels identifying obj in the metrics.
+func ownerLabels(obj client.Object) prometheus.Labels {
+	return prometheus.Labels{"namespace": obj.GetNamespace(), "name": obj.GetName()}
+}
+
+// recordPolicyMatch counts an evaluation of the policies of obj that matched the policy at index.
+func recordPolicyMatch(obj client.Object, index int, name string) {
+	policyMatches.WithLabelValues(obj.GetNamespace(), obj.GetName(), strconv.Itoa(index), name).Inc()
+}
+
+// recordPolicyError counts an evaluation of the policies of obj that failed for reason.
+func recordPolicyError(obj client.Object, reason string) {
+	policyErrors.WithLabelValues(obj.GetNamespace(), obj.GetName(), reason).Inc()
+}
+
+// observeEvaluation records the latency of an evaluation started at start.
+func observeEvaluation(language string, start time.Time) {
+	expressionEvaluationDuration.WithLabelValues(language).Observe(time.Since(start).Seconds())
+}
+
+// recordVPAOperation counts a VerticalPodAutoscaler created, updated, adopted or deleted for obj.
+func recordVPAOperation(obj client.Object, operation string) {
+	vpaOperations.WithLabelValues(obj.GetNamespace(), obj.GetName(), operation).Inc()
+}
+
+// setUpdateMode records the effective updateMode of the VerticalPodAutoscaler vpaKey managed for obj.
+func setUpdateMode(obj client.Object, vpaKey client.ObjectKey, spec *vpa.VerticalPodAutoscalerSpec) {
+	deleteUpdateMode(obj, vpaKey)
+	vpaUpdateMode.WithLabelValues(obj.GetNamespace(), obj.GetName(), vpaKey.String(), string(effectiveUpdateMode(spec))).Set(1)
+}
+
+// deleteUpdateMode removes the updateMode of the VerticalPodAutoscaler vpaKey managed for obj.
+func deleteUpdateMode(obj client.Object, vpaKey client.ObjectKey) {
+	labels := ownerLabels(obj)
+	labels["vpa"] = vpaKey.String()
+	vpaUpdateMode.DeletePartialMatch(labels)
+}
+
+// deleteObjectMetrics removes the metrics of a deleted object.
+func deleteObjectMetrics(key client.ObjectKey) {
+	labels := prometheus.Labels{"namespace": key.Namespace, "name": key.Name}
+	policyMatches.DeletePartialMatch(labels)
+	policyErrors.DeletePartialMatch(labels)
+	vpaOperations.DeletePartialMatch(labels)
+	vpaUpdateMode.DeletePartialMatch(labels)
+}
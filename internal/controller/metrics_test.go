@@ -0,0 +1,62 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Metrics", func() {
+	obj := &v1alpha1.DynamicVerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "default"},
+	}
+	vpaKey := client.ObjectKeyFromObject(obj)
+
+	AfterEach(func() {
+		deleteObjectMetrics(client.ObjectKeyFromObject(obj))
+	})
+
+	It("should count the matched policies", func() {
+		recordPolicyMatch(obj, 1, "default")
+		recordPolicyMatch(obj, 1, "default")
+		Expect(testutil.ToFloat64(policyMatches.WithLabelValues("default", "metrics", "1", "default"))).To(Equal(2.0))
+	})
+
+	It("should only report the current updateMode", func() {
+		setUpdateMode(obj, vpaKey, &vpa.VerticalPodAutoscalerSpec{
+			UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff},
+		})
+		setUpdateMode(obj, vpaKey, &vpa.VerticalPodAutoscalerSpec{})
+		Expect(testutil.ToFloat64(vpaUpdateMode.WithLabelValues("default", "metrics", "default/metrics", "Auto"))).To(Equal(1.0))
+		Expect(vpaUpdateMode.DeletePartialMatch(ownerLabels(obj))).To(Equal(1))
+	})
+
+	It("should delete the metrics of deleted objects", func() {
+		recordPolicyError(obj, v1alpha1.ReasonNoMatch)
+		setUpdateMode(obj, vpaKey, &vpa.VerticalPodAutoscalerSpec{})
+		deleteObjectMetrics(client.ObjectKeyFromObject(obj))
+		Expect(policyErrors.DeletePartialMatch(ownerLabels(obj))).To(BeZero())
+		Expect(vpaUpdateMode.DeletePartialMatch(ownerLabels(obj))).To(BeZero())
+	})
+})
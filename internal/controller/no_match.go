@@ -0,0 +1,124 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	autoscaling "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// noMatchActionOrDefault returns the no-match action, defaulted to Error.
+func noMatchActionOrDefault(action v1alpha1.NoMatchAction) v1alpha1.NoMatchAction {
+	if len(action) == 0 {
+		return v1alpha1.NoMatchActionError
+	}
+	return action
+}
+
+// validateNoMatchAction checks that defaultVpaSpec is set, without expressions, if and only if
+// the noMatchAction is Default.
+func validateNoMatchAction(action v1alpha1.NoMatchAction, defaultVpaSpec *v1alpha1.VpaSpec, specPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	defaultPath := specPath.Child("defaultVpaSpec")
+	switch {
+	case action == v1alpha1.NoMatchActionDefault && defaultVpaSpec == nil:
+		errs = append(errs, field.Required(defaultPath, "required when noMatchAction is Default"))
+	case action != v1alpha1.NoMatchActionDefault && defaultVpaSpec != nil:
+		errs = append(errs, field.Forbidden(defaultPath, "only allowed when noMatchAction is Default"))
+	case defaultVpaSpec != nil && defaultVpaSpec.Expressions != nil:
+		errs = append(errs, field.Forbidden(defaultPath.Child("expressions"), "expressions are not supported in the defaultVpaSpec"))
+	}
+	return errs
+}
+
+// reconcileNoMatch applies the noMatchAction of src, other than Error, to the VerticalPodAutoscaler vpaKey
+// of a target matched by no policy. The outcome is reported in res.
+func (t *targetReconciler) reconcileNoMatch(
+	ctx context.Context,
+	src *policySource,
+	targetRef *autoscaling.CrossVersionObjectReference,
+	vpaTarget *unstructured.Unstructured,
+	vpaKey client.ObjectKey,
+	existingVpa *vpa.VerticalPodAutoscaler,
+	vpaExists bool,
+	res *targetResult,
+) error {
+	res.noMatchAction = noMatchActionOrDefault(src.noMatchAction)
+	log.FromContext(ctx).V(5).Info("No policy matched", "noMatchAction", res.noMatchAction)
+
+	switch res.noMatchAction {
+	case v1alpha1.NoMatchActionKeep:
+		res.syncReason = v1alpha1.ReasonKept
+		return nil
+	case v1alpha1.NoMatchActionDelete:
+		return t.deleteVPA(ctx, src, vpaTarget, vpaKey, existingVpa, vpaExists, res)
+	}
+
+	// The defaultVpaSpec has no expressions, nothing is computed.
+	wantVpaSpec, err := makeVpaSpec(targetRef, src.defaultVpaSpec, nil)
+	if err != nil {
+		return withReason(v1alpha1.ReasonInvalidSpec, err)
+	}
+	return t.writeVPA(ctx, src, vpaTarget, vpaKey, existingVpa, vpaExists, wantVpaSpec, -1, res)
+}
+
+// deleteVPA deletes the VerticalPodAutoscaler vpaKey if it is controlled by src, unless src is in DryRun mode.
+// The outcome is reported in res.
+func (t *targetReconciler) deleteVPA(
+	ctx context.Context,
+	src *policySource,
+	vpaTarget *unstructured.Unstructured,
+	vpaKey client.ObjectKey,
+	existingVpa *vpa.VerticalPodAutoscaler,
+	vpaExists bool,
+	res *targetResult,
+) error {
+	res.syncReason = v1alpha1.ReasonDeleted
+	if !vpaExists || !metav1.IsControlledBy(existingVpa, src.obj) {
+		return nil
+	}
+
+	if src.dryRun {
+		res.dryRun = &v1alpha1.DryRunStatus{Action: v1alpha1.DryRunActionDelete}
+		res.syncReason = v1alpha1.ReasonDryRun
+		t.recordDryRun(src, vpaKey, -1, res.dryRun)
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Deleting VerticalPodAutoscaler of a target matched by no policy", "vpa", vpaKey.Name)
+	if err := t.Delete(ctx, existingVpa); client.IgnoreNotFound(err) != nil {
+		t.targetEvent(src, vpaTarget, corev1.EventTypeWarning, v1alpha1.ReasonSyncFailed,
+			fmt.Sprintf("failed to delete VerticalPodAutoscaler %s: %v", vpaKey, err))
+		return withReason(v1alpha1.ReasonSyncFailed, err)
+	}
+	recordVPAOperation(src.obj, "delete")
+	deleteUpdateMode(src.obj, vpaKey)
+	t.targetEvent(src, vpaTarget, corev1.EventTypeNormal, reasonVPADeleted,
+		fmt.Sprintf("deleted VerticalPodAutoscaler %s, as no policy matched", vpaKey))
+	return nil
+}
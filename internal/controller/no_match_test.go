@@ -0,0 +1,56 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("noMatchAction", func() {
+	It("should default to Error", func() {
+		Expect(noMatchActionOrDefault("")).To(Equal(v1alpha1.NoMatchActionError))
+		Expect(noMatchActionOrDefault(v1alpha1.NoMatchActionKeep)).To(Equal(v1alpha1.NoMatchActionKeep))
+	})
+
+	It("should only count the actions other than Error as applied", func() {
+		Expect(targetResult{}.noMatchActed()).To(BeFalse())
+		Expect(targetResult{noMatchAction: v1alpha1.NoMatchActionError}.noMatchActed()).To(BeFalse())
+		Expect(targetResult{noMatchAction: v1alpha1.NoMatchActionKeep}.noMatchActed()).To(BeTrue())
+	})
+
+	DescribeTable("should validate the defaultVpaSpec",
+		func(action v1alpha1.NoMatchAction, defaultVpaSpec *v1alpha1.VpaSpec, valid bool) {
+			errs := validateNoMatchAction(action, defaultVpaSpec, field.NewPath("spec"))
+			if valid {
+				Expect(errs).To(BeEmpty())
+			} else {
+				Expect(errs).To(HaveLen(1))
+			}
+		},
+		Entry("no action", v1alpha1.NoMatchAction(""), nil, true),
+		Entry("Keep", v1alpha1.NoMatchActionKeep, nil, true),
+		Entry("Default", v1alpha1.NoMatchActionDefault, &v1alpha1.VpaSpec{}, true),
+		Entry("Default without defaultVpaSpec", v1alpha1.NoMatchActionDefault, nil, false),
+		Entry("defaultVpaSpec with Delete", v1alpha1.NoMatchActionDelete, &v1alpha1.VpaSpec{}, false),
+		Entry("defaultVpaSpec with expressions", v1alpha1.NoMatchActionDefault,
+			&v1alpha1.VpaSpec{Expressions: &v1alpha1.VpaSpecExpressions{UpdateMode: `"Off"`}}, false),
+	)
+})
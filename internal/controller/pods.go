@@ -0,0 +1,152 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// controllerIndexKey is the field index used to find the Pods, ReplicaSets and Jobs controlled by an object.
+const controllerIndexKey = ".metadata.controller"
+
+// indexController is the client.IndexerFunc for controllerIndexKey. It indexes the UID of the controller.
+func indexController(o client.Object) []string {
+	owner := metav1.GetControllerOf(o)
+	if owner == nil {
+		return nil
+	}
+	return []string{string(owner.UID)}
+}
+
+// SetupPodIndexes registers the field indexes used to list the Pods of the targets from the cache.
+// It must be called once per manager, before the reconcilers are set up with PodsIndexed.
+func SetupPodIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &corev1.Pod{}, controllerIndexKey, indexController); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &appsv1.ReplicaSet{}, controllerIndexKey, indexController); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &batchv1.Job{}, controllerIndexKey, indexController)
+}
+
+// listTargetPods returns the Pods controlled by target, or by the objects controlled by target which control
+// its Pods, such as the ReplicaSets of a Deployment or the Jobs of a CronJob.
+// The Pods are listed from the cache with controllerIndexKey, which must have been registered by SetupPodIndexes.
+func (t *targetReconciler) listTargetPods(ctx context.Context, target *unstructured.Unstructured) ([]corev1.Pod, error) {
+	owners := []string{string(target.GetUID())}
+	if podOwner := t.workloads.get(target.GroupVersionKind().GroupKind()).podOwner; !podOwner.Empty() {
+		controlled, err := t.listControlled(ctx, podOwner, target.GetNamespace(), string(target.GetUID()))
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, controlled...)
+	}
+
+	var pods []corev1.Pod
+	for _, owner := range owners {
+		var list corev1.PodList
+		if err := t.List(ctx, &list,
+			client.InNamespace(target.GetNamespace()),
+			client.MatchingFields{controllerIndexKey: owner},
+		); err != nil {
+			return nil, err
+		}
+		pods = append(pods, list.Items...)
+	}
+	return pods, nil
+}
+
+// setPods sets the pods variable of env. Every Pod is exposed as a map with its name, labels, phase,
+// creationTimestamp, age, whether it is ready, the sum of the restarts of its containers, and its containers.
+func setPods(env map[string]interface{}, pods []corev1.Pod, now time.Time) {
+	values := make([]interface{}, 0, len(pods))
+	for i := range pods {
+		values = append(values, podValue(&pods[i], now))
+	}
+	env["pods"] = values
+}
+
+// podValue returns the representation of a Pod in the pods variable.
+func podValue(pod *corev1.Pod, now time.Time) map[string]interface{} {
+	statuses := make(map[string]*corev1.ContainerStatus, len(pod.Status.ContainerStatuses))
+	for i := range pod.Status.ContainerStatuses {
+		statuses[pod.Status.ContainerStatuses[i].Name] = &pod.Status.ContainerStatuses[i]
+	}
+
+	restarts := 0
+	containers := make([]interface{}, 0, len(pod.Spec.Containers))
+	for _, container := range pod.Spec.Containers {
+		requests := make(map[string]interface{}, len(container.Resources.Requests))
+		for name, quantity := range container.Resources.Requests {
+			requests[string(name)] = quantity.String()
+		}
+		value := map[string]interface{}{
+			"name":            container.Name,
+			"requests":        requests,
+			"ready":           false,
+			"restartCount":    0,
+			"lastTermination": nil,
+		}
+		if status, ok := statuses[container.Name]; ok {
+			value["ready"] = status.Ready
+			value["restartCount"] = int(status.RestartCount)
+			restarts += int(status.RestartCount)
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				value["lastTermination"] = map[string]interface{}{
+					"reason":     terminated.Reason,
+					"exitCode":   int(terminated.ExitCode),
+					"finishedAt": terminated.FinishedAt.Time,
+				}
+			}
+		}
+		containers = append(containers, value)
+	}
+
+	labels := make(map[string]interface{}, len(pod.Labels))
+	for key, value := range pod.Labels {
+		labels[key] = value
+	}
+	return map[string]interface{}{
+		"name":              pod.Name,
+		"labels":            labels,
+		"phase":             string(pod.Status.Phase),
+		"creationTimestamp": pod.CreationTimestamp.Time,
+		"age":               now.Sub(pod.CreationTimestamp.Time),
+		"ready":             podReady(pod),
+		"restartCount":      restarts,
+		"containers":        containers,
+	}
+}
+
+// podReady returns whether the Ready condition of a Pod is True.
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
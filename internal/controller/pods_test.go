@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Pods", func() {
+	now := time.Now()
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "example-abc",
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "example", UID: "replicaset", Controller: ptr.To(true)},
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("100Mi"),
+				}}},
+				{Name: "sidecar"},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "app",
+				Ready:        true,
+				RestartCount: 2,
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Reason:     "OOMKilled",
+					ExitCode:   137,
+					FinishedAt: metav1.NewTime(now.Add(-10 * time.Minute)),
+				}},
+			}},
+		},
+	}
+
+	It("should index the controller of an object", func() {
+		Expect(indexController(&pod)).To(ConsistOf("replicaset"))
+		Expect(indexController(&corev1.Pod{})).To(BeEmpty())
+	})
+
+	DescribeTable("should expose the pods to the conditions",
+		func(language v1alpha1.ConditionLanguage, condition string) {
+			env := programEnv(nil, nil, nil)
+			p, err := compileCondition(language, condition, env)
+			Expect(err).NotTo(HaveOccurred())
+
+			setPods(env, []corev1.Pod{pod}, now)
+			matched, err := p.run(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+		},
+		Entry("expr pod fields", v1alpha1.ConditionLanguageExpr,
+			`pods[0].phase == "Running" && pods[0].ready && pods[0].restartCount == 2 && pods[0].age > duration("1h")`),
+		Entry("expr containers", v1alpha1.ConditionLanguageExpr,
+			`pods[0].containers[0].requests.memory == "100Mi" && pods[0].containers[1].lastTermination == nil`),
+		Entry("expr OOMKilled", v1alpha1.ConditionLanguageExpr,
+			`any(pods, any(.containers, .lastTermination?.reason == "OOMKilled" && now() - .lastTermination.finishedAt < duration("1h")))`),
+		Entry("cel pod fields", v1alpha1.ConditionLanguageCEL,
+			`pods[0].phase == "Running" && pods[0].ready && pods[0].restartCount == 2 && pods[0].age > duration("1h")`),
+		Entry("cel OOMKilled", v1alpha1.ConditionLanguageCEL,
+			`pods.exists(p, p.containers.exists(c, c.lastTermination != null &&
+				c.lastTermination.reason == "OOMKilled" && now() - c.lastTermination.finishedAt < duration("1h")))`),
+	)
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// policyTemplateIndexKey is the field index used to find the DynamicVerticalPodAutoscalers referencing a policy template.
+const policyTemplateIndexKey = ".spec.policyTemplateRef.name"
+
+// indexPolicyTemplate is the client.IndexerFunc for policyTemplateIndexKey.
+func indexPolicyTemplate(o client.Object) []string {
+	obj, ok := o.(*v1alpha1.DynamicVerticalPodAutoscaler)
+	if !ok || obj.Spec.PolicyTemplateRef == nil {
+		return nil
+	}
+	return []string{obj.Spec.PolicyTemplateRef.Name}
+}
+
+// resolvePolicySource returns the policySource of obj, with the policies of its policy template, if any.
+// The evaluated revision of the template is recorded in the status of obj.
+func (r *DynamicVerticalPodAutoscalerReconciler) resolvePolicySource(
+	ctx context.Context,
+	obj *v1alpha1.DynamicVerticalPodAutoscaler,
+) (*policySource, error) {
+	obj.Status.PolicyTemplate = nil
+
+	src := namespacedPolicySource(obj)
+	src.dryRun = src.dryRun || r.DryRun
+	ref := obj.Spec.PolicyTemplateRef
+	if ref == nil {
+		return src, nil
+	}
+
+	var template v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate
+	if err := r.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: ref.Name}, &template); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, withReason(v1alpha1.ReasonPolicyTemplateNotFound,
+				fmt.Errorf("DynamicVerticalPodAutoscalerPolicyTemplate %q not found", ref.Name))
+		}
+		return nil, err
+	}
+
+	src.template = &template
+	src.policies = mergePolicies(ref.LocalPolicies, obj.Spec.Policies, &template)
+	obj.Status.PolicyTemplate = &v1alpha1.PolicyTemplateStatus{Name: template.Name, Generation: template.Generation}
+	return src, nil
+}
+
+// mergePolicies returns the policies of the template along with the local policies, in evaluation order.
+// The policies of the template without language inherit the language of the template.
+func mergePolicies(
+	placement v1alpha1.LocalPoliciesPlacement,
+	local []v1alpha1.DynamicVerticalPodAutoscalerPolicy,
+	template *v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate,
+) []v1alpha1.DynamicVerticalPodAutoscalerPolicy {
+	fromTemplate := make([]v1alpha1.DynamicVerticalPodAutoscalerPolicy, 0, len(template.Spec.Policies))
+	for _, policy := range template.Spec.Policies {
+		if len(policy.Language) == 0 {
+			policy.Language = template.Spec.Language
+		}
+		fromTemplate = append(fromTemplate, policy)
+	}
+
+	policies := make([]v1alpha1.DynamicVerticalPodAutoscalerPolicy, 0, len(local)+len(fromTemplate))
+	if placement == v1alpha1.LocalPoliciesPrepend {
+		policies = append(policies, local...)
+		return append(policies, fromTemplate...)
+	}
+	policies = append(policies, fromTemplate...)
+	return append(policies, local...)
+}
+
+// findObjectsForPolicyTemplate maps a policy template to the DynamicVerticalPodAutoscalers referencing it.
+func (r *DynamicVerticalPodAutoscalerReconciler) findObjectsForPolicyTemplate(ctx context.Context, template client.Object) []reconcile.Request {
+	var list v1alpha1.DynamicVerticalPodAutoscalerList
+	if err := r.List(ctx, &list,
+		client.InNamespace(template.GetNamespace()),
+		client.MatchingFields{policyTemplateIndexKey: template.GetName()},
+	); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list DynamicVerticalPodAutoscalers for policy template",
+			"name", template.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	return requests
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("mergePolicies", func() {
+	local := []v1alpha1.DynamicVerticalPodAutoscalerPolicy{{Name: "local"}}
+	template := &v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate{
+		Spec: v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplateSpec{
+			Language: v1alpha1.ConditionLanguageCEL,
+			Policies: []v1alpha1.DynamicVerticalPodAutoscalerPolicy{
+				{Name: "inherited"},
+				{Name: "explicit", Language: v1alpha1.ConditionLanguageExpr},
+			},
+		},
+	}
+
+	names := func(policies []v1alpha1.DynamicVerticalPodAutoscalerPolicy) []string {
+		var result []string
+		for _, policy := range policies {
+			result = append(result, policy.Name)
+		}
+		return result
+	}
+
+	It("should append the local policies by default", func() {
+		Expect(names(mergePolicies("", local, template))).To(Equal([]string{"inherited", "explicit", "local"}))
+	})
+
+	It("should prepend the local policies", func() {
+		Expect(names(mergePolicies(v1alpha1.LocalPoliciesPrepend, local, template))).
+			To(Equal([]string{"local", "inherited", "explicit"}))
+	})
+
+	It("should apply the language of the template", func() {
+		policies := mergePolicies(v1alpha1.LocalPoliciesAppend, local, template)
+		Expect(policies[0].Language).To(Equal(v1alpha1.ConditionLanguageCEL))
+		Expect(policies[1].Language).To(Equal(v1alpha1.ConditionLanguageExpr))
+		Expect(policies[2].Language).To(BeEmpty())
+		Expect(template.Spec.Policies[0].Language).To(BeEmpty())
+	})
+})
+
+var _ = Describe("indexPolicyTemplate", func() {
+	It("should index the name of the policy template", func() {
+		obj := &v1alpha1.DynamicVerticalPodAutoscaler{
+			Spec: v1alpha1.DynamicVerticalPodAutoscalerSpec{
+				PolicyTemplateRef: &v1alpha1.PolicyTemplateReference{Name: "example"},
+			},
+		}
+		Expect(indexPolicyTemplate(obj)).To(Equal([]string{"example"}))
+		Expect(indexPolicyTemplate(&v1alpha1.DynamicVerticalPodAutoscaler{})).To(BeEmpty())
+	})
+})
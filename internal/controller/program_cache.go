@@ -0,0 +1,78 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/lru"
+)
+
+// DefaultProgramCacheSize is the default maximum number of compiled conditions kept in memory.
+const DefaultProgramCacheSize = 4096
+
+// programKey identifies the compiled condition, or computed field, of a policy.
+// The generations are part of the key, so that entries are invalidated whenever the spec
+// of the object or of its policy template changes. The UID of the template is part of it too,
+// as a template recreated under the same name starts again at the same generation.
+type programKey struct {
+	uid                types.UID
+	generation         int64
+	templateUID        types.UID
+	templateGeneration int64
+	index              int
+	// field is the path of the computed field in the policy, or empty for the condition.
+	field string
+}
+
+// programCache is a bounded, thread-safe cache of compiled conditions.
+// Entries of older generations are never hit again and are eventually evicted.
+type programCache struct {
+	cache *lru.Cache
+}
+
+func newProgramCache(size int) *programCache {
+	if size <= 0 {
+		size = DefaultProgramCacheSize
+	}
+	return &programCache{cache: lru.New(size)}
+}
+
+// getOrCompile returns the cached program for key, or compiles and caches it.
+// Compilation errors are not cached.
+func (c *programCache) getOrCompile(key programKey, compile func() (program, error)) (program, error) {
+	return getOrCompile(c, key, compile)
+}
+
+// getOrCompileValue is the getOrCompile counterpart for the expressions computing fields.
+func (c *programCache) getOrCompileValue(key programKey, compile func() (valueProgram, error)) (valueProgram, error) {
+	return getOrCompile(c, key, compile)
+}
+
+func getOrCompile[T any](c *programCache, key programKey, compile func() (T, error)) (T, error) {
+	if p, ok := c.cache.Get(key); ok {
+		programCacheHits.Inc()
+		return p.(T), nil
+	}
+	programCacheMisses.Inc()
+
+	p, err := compile()
+	if err != nil {
+		return p, err
+	}
+	c.cache.Add(key, p)
+	return p, nil
+}
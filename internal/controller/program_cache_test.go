@@ -0,0 +1,101 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("programCache", func() {
+	var compilations int
+	compile := func() (program, error) {
+		compilations++
+		return compileCondition(v1alpha1.ConditionLanguageExpr, "true", programEnv(nil, nil, nil))
+	}
+
+	BeforeEach(func() {
+		compilations = 0
+	})
+
+	It("should compile a condition only once per generation", func() {
+		cache := newProgramCache(10)
+		key := programKey{uid: "uid", generation: 1, index: 0}
+
+		first, err := cache.getOrCompile(key, compile)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := cache.getOrCompile(key, compile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).To(BeIdenticalTo(first))
+		Expect(compilations).To(Equal(1))
+
+		By("bumping the generation")
+		key.generation = 2
+		_, err = cache.getOrCompile(key, compile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(compilations).To(Equal(2))
+	})
+
+	It("should compile a condition again when the policy template is recreated", func() {
+		cache := newProgramCache(10)
+		template := &v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", UID: "template", Generation: 1},
+		}
+		src := namespacedPolicySource(&v1alpha1.DynamicVerticalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", UID: "uid", Generation: 1},
+		})
+		src.template = template
+
+		_, err := cache.getOrCompile(src.programKey(0, ""), compile)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = cache.getOrCompile(src.programKey(0, ""), compile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(compilations).To(Equal(1))
+
+		By("recreating the template under the same name")
+		src.template = template.DeepCopy()
+		src.template.UID = "recreated"
+		_, err = cache.getOrCompile(src.programKey(0, ""), compile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(compilations).To(Equal(2))
+	})
+
+	It("should be bounded", func() {
+		cache := newProgramCache(1)
+		_, _ = cache.getOrCompile(programKey{uid: "uid", index: 0}, compile)
+		_, _ = cache.getOrCompile(programKey{uid: "uid", index: 1}, compile)
+		_, _ = cache.getOrCompile(programKey{uid: "uid", index: 0}, compile)
+		Expect(compilations).To(Equal(3))
+	})
+
+	It("should not cache compilation errors", func() {
+		cache := newProgramCache(10)
+		key := programKey{uid: "uid"}
+		_, err := cache.getOrCompile(key, func() (program, error) {
+			return nil, errors.New("boom")
+		})
+		Expect(err).To(HaveOccurred())
+		_, err = cache.getOrCompile(key, compile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(compilations).To(Equal(1))
+	})
+})
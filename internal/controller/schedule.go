@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/robfig/cron/v3"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// windowsVariable is the variable of the environment recording the next window boundary
+// of the windows evaluated by the conditions, so that they are re-evaluated at that time.
+// It is a map, rather than a struct, so that it can be passed to CEL functions.
+const (
+	windowsVariable = "_windows"
+	nextBoundaryKey = "next"
+)
+
+// timeWindow is a recurring time window.
+type timeWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// parseWindow parses a window starting on the standard cron expression spec, in the IANA time zone timeZone.
+// An empty time zone is UTC.
+func parseWindow(spec string, duration time.Duration, timeZone string) (*timeWindow, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("invalid window duration %s: must be positive", duration)
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	return &timeWindow{schedule: schedule, duration: duration, location: location}, nil
+}
+
+// policyWindow returns the window of a policy schedule.
+func policyWindow(schedule *v1alpha1.PolicySchedule) (*timeWindow, error) {
+	return parseWindow(schedule.Cron, schedule.Duration.Duration, schedule.TimeZone)
+}
+
+// contains returns whether now is in a window, and the next time at which the window starts or ends.
+func (w *timeWindow) contains(now time.Time) (bool, time.Time) {
+	now = now.In(w.location)
+	// The windows are started on whole seconds, and last started strictly after now - duration.
+	start := w.schedule.Next(now.Add(-w.duration))
+	if start.After(now) {
+		return false, start
+	}
+	return true, start.Add(w.duration)
+}
+
+// setWindows sets the inWindow helper of env, and the variable recording the window boundaries.
+func setWindows(env map[string]interface{}) {
+	windows := map[string]interface{}{}
+	env[windowsVariable] = windows
+	// The helper is only called by expr programs. CEL programs call the function
+	// declared by windowCELOptions, which receives the windows variable as argument.
+	env["inWindow"] = func(spec, duration string, timeZone ...string) (bool, error) {
+		if len(timeZone) > 1 {
+			return false, fmt.Errorf("inWindow expects at most 3 arguments")
+		}
+		return inWindow(windows, spec, duration, append(timeZone, "")[0], time.Now())
+	}
+}
+
+// inWindow returns whether now is in a window starting on the cron expression spec, in the time zone timeZone,
+// and lasting duration. The next boundary of the window is recorded in windows.
+func inWindow(windows map[string]interface{}, spec, duration, timeZone string, now time.Time) (bool, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return false, fmt.Errorf("invalid window duration %q: %w", duration, err)
+	}
+	window, err := parseWindow(spec, d, timeZone)
+	if err != nil {
+		return false, err
+	}
+	in, boundary := window.contains(now)
+	recordBoundary(windows, boundary)
+	return in, nil
+}
+
+// recordBoundary records boundary in windows if it is earlier than the boundaries already recorded.
+func recordBoundary(windows map[string]interface{}, boundary time.Time) {
+	if windows == nil {
+		return
+	}
+	if next, ok := windows[nextBoundaryKey].(time.Time); ok && !boundary.Before(next) {
+		return
+	}
+	windows[nextBoundaryKey] = boundary
+}
+
+// nextWindowBoundary returns the earliest boundary of the windows evaluated against env, if any.
+func nextWindowBoundary(env map[string]interface{}) time.Time {
+	windows, _ := env[windowsVariable].(map[string]interface{})
+	next, _ := windows[nextBoundaryKey].(time.Time)
+	return next
+}
+
+// windowCELOptions declares the inWindow helper in CEL. Its macros pass the windows variable
+// to the function implementing it, e.g. inWindow(spec, duration) is expanded to inWindow(_windows, spec, duration).
+func windowCELOptions() []cel.EnvOption {
+	expander := func(eh cel.MacroExprHelper, _ *exprpb.Expr, args []*exprpb.Expr) (*exprpb.Expr, *common.Error) {
+		return eh.GlobalCall("inWindow", append([]*exprpb.Expr{eh.Ident(windowsVariable)}, args...)...), nil
+	}
+	binding := func(args ...ref.Val) ref.Val {
+		windows, _ := args[0].Value().(map[string]interface{})
+		strs := make([]string, 3)
+		for i, arg := range args[1:] {
+			str, ok := arg.(types.String)
+			if !ok {
+				return types.MaybeNoSuchOverloadErr(arg)
+			}
+			strs[i] = string(str)
+		}
+		in, err := inWindow(windows, strs[0], strs[1], strs[2], time.Now())
+		if err != nil {
+			return types.NewErr("%v", err)
+		}
+		return types.Bool(in)
+	}
+
+	return []cel.EnvOption{
+		cel.Macros(
+			cel.NewGlobalMacro("inWindow", 2, expander),
+			cel.NewGlobalMacro("inWindow", 3, expander),
+		),
+		cel.Function("inWindow",
+			cel.Overload("inWindow_dyn_string_string",
+				[]*cel.Type{cel.DynType, cel.StringType, cel.StringType}, cel.BoolType,
+				cel.FunctionBinding(binding),
+			),
+			cel.Overload("inWindow_dyn_string_string_string",
+				[]*cel.Type{cel.DynType, cel.StringType, cel.StringType, cel.StringType}, cel.BoolType,
+				cel.FunctionBinding(binding),
+			),
+		),
+	}
+}
+
+// boundaryResult returns result, requeued at boundary if it is sooner than the requeue of result.
+// A zero boundary leaves result unchanged.
+func boundaryResult(result ctrl.Result, boundary, now time.Time) ctrl.Result {
+	if boundary.IsZero() {
+		return result
+	}
+	after := boundary.Sub(now)
+	if after <= 0 {
+		after = time.Second
+	}
+	if result.RequeueAfter <= 0 || after < result.RequeueAfter {
+		result.RequeueAfter = after
+	}
+	return result
+}
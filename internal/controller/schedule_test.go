@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Time windows", func() {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	Expect(err).NotTo(HaveOccurred())
+
+	// Every Saturday from 02:00 to 06:00 in Berlin.
+	window, err := parseWindow("0 2 * * 6", 4*time.Hour, "Europe/Berlin")
+	Expect(err).NotTo(HaveOccurred())
+
+	DescribeTable("should report whether a time is in a window and the next boundary",
+		func(now time.Time, wantIn bool, wantBoundary time.Time) {
+			in, boundary := window.contains(now)
+			Expect(in).To(Equal(wantIn))
+			Expect(boundary).To(BeTemporally("==", wantBoundary))
+		},
+		Entry("before the window",
+			time.Date(2024, 6, 8, 1, 30, 0, 0, berlin), false, time.Date(2024, 6, 8, 2, 0, 0, 0, berlin)),
+		Entry("at the start of the window",
+			time.Date(2024, 6, 8, 2, 0, 0, 0, berlin), true, time.Date(2024, 6, 8, 6, 0, 0, 0, berlin)),
+		Entry("in the window, in another time zone",
+			time.Date(2024, 6, 8, 3, 0, 0, 0, time.UTC), true, time.Date(2024, 6, 8, 6, 0, 0, 0, berlin)),
+		Entry("at the end of the window",
+			time.Date(2024, 6, 8, 6, 0, 0, 0, berlin), false, time.Date(2024, 6, 15, 2, 0, 0, 0, berlin)),
+	)
+
+	It("should reject invalid windows", func() {
+		_, err := parseWindow("0 2 * *", time.Hour, "")
+		Expect(err).To(HaveOccurred())
+		_, err = parseWindow("0 2 * * 6", 0, "")
+		Expect(err).To(HaveOccurred())
+		_, err = parseWindow("0 2 * * 6", time.Hour, "Europe/Nowhere")
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("should record the next boundary of the windows evaluated by the conditions",
+		func(language v1alpha1.ConditionLanguage, condition string) {
+			env := programEnv(nil, nil, nil)
+			p, err := compileCondition(language, condition, env)
+			Expect(err).NotTo(HaveOccurred())
+
+			matched, err := p.run(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+			Expect(nextWindowBoundary(env)).To(BeTemporally("~", time.Now().Add(time.Minute), time.Minute))
+		},
+		Entry("expr", v1alpha1.ConditionLanguageExpr,
+			`inWindow("* * * * *", "24h", "Europe/Berlin") && !inWindow("0 0 1 1 *", "1m")`),
+		Entry("cel", v1alpha1.ConditionLanguageCEL,
+			`inWindow("* * * * *", "24h", "Europe/Berlin") && !inWindow("0 0 1 1 *", "1m")`),
+	)
+
+	It("should requeue at the earliest boundary", func() {
+		now := time.Now()
+		result := boundaryResult(ctrl.Result{RequeueAfter: time.Minute}, now.Add(10*time.Second), now)
+		Expect(result.RequeueAfter).To(Equal(10 * time.Second))
+		result = boundaryResult(ctrl.Result{RequeueAfter: time.Minute}, time.Time{}, now)
+		Expect(result.RequeueAfter).To(Equal(time.Minute))
+	})
+})
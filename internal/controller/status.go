@@ -0,0 +1,187 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// reconcileError is an error that carries the reason reported on the Ready condition.
+type reconcileError struct {
+	reason string
+	err    error
+}
+
+func (e *reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.err
+}
+
+// withReason annotates err with the reason reported on the Ready condition.
+func withReason(reason string, err error) error {
+	return &reconcileError{reason: reason, err: err}
+}
+
+// expressionError is returned when a policy condition fails to compile or evaluate.
+type expressionError struct {
+	reason string
+	index  int
+	err    error
+}
+
+func (e *expressionError) Error() string {
+	return fmt.Sprintf("policy %d: %v", e.index, e.err)
+}
+
+func (e *expressionError) Unwrap() error {
+	return e.err
+}
+
+// setCondition sets the given condition on the status of obj.
+func setCondition(obj *v1alpha1.DynamicVerticalPodAutoscaler, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	setStatusCondition(&obj.Status, obj.Generation, conditionType, status, reason, message)
+}
+
+// setStatusCondition sets the given condition on a status observed at the given generation.
+func setStatusCondition(
+	objStatus *v1alpha1.DynamicVerticalPodAutoscalerStatus,
+	generation int64,
+	conditionType string,
+	status metav1.ConditionStatus,
+	reason, message string,
+) {
+	meta.SetStatusCondition(&objStatus.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// stepConditions are the conditions reporting the steps of a reconciliation,
+// which are only set when their step runs.
+var stepConditions = []string{
+	v1alpha1.ConditionTargetFound,
+	v1alpha1.ConditionExpressionError,
+	v1alpha1.ConditionPolicyMatched,
+	v1alpha1.ConditionVPASynced,
+}
+
+// trackSteps clears the stepConditions of objStatus before a reconciliation, so that they are only
+// reported again by the steps that run. The returned function must be called with the error returned by
+// the reconciliation: the conditions of the steps that did not run are set to Unknown with the reason
+// of the failure, or restored when there was none. The conditions keep their position, and their last
+// transition time when their status is unchanged, so that an unchanged status is not written again.
+func trackSteps(objStatus *v1alpha1.DynamicVerticalPodAutoscalerStatus, generation int64) func(err error) {
+	previous := make(map[string]metav1.Condition, len(stepConditions))
+	for _, conditionType := range stepConditions {
+		if condition := meta.FindStatusCondition(objStatus.Conditions, conditionType); condition != nil {
+			previous[conditionType] = *condition
+			// A condition without status is not set by the current reconciliation.
+			condition.Status = ""
+		}
+	}
+
+	return func(err error) {
+		for _, conditionType := range stepConditions {
+			prev, hadPrevious := previous[conditionType]
+			condition := meta.FindStatusCondition(objStatus.Conditions, conditionType)
+			switch {
+			case condition != nil && len(condition.Status) > 0:
+			case err != nil:
+				setStatusCondition(objStatus, generation, conditionType, metav1.ConditionUnknown, failureReason(err),
+					fmt.Sprintf("not evaluated: %v", err))
+				condition = meta.FindStatusCondition(objStatus.Conditions, conditionType)
+			case condition != nil:
+				*condition = prev
+				continue
+			default:
+				continue
+			}
+			if hadPrevious && prev.Status == condition.Status {
+				condition.LastTransitionTime = prev.LastTransitionTime
+			}
+		}
+	}
+}
+
+// failureReason returns the reason reported on the Ready condition for err.
+func failureReason(err error) string {
+	var rErr *reconcileError
+	if errors.As(err, &rErr) {
+		return rErr.reason
+	}
+	return v1alpha1.ReasonReconcileFailed
+}
+
+// readyStatus sets the Ready condition and the observed generation after a reconciliation that returned err.
+func readyStatus(objStatus *v1alpha1.DynamicVerticalPodAutoscalerStatus, generation int64, err error) {
+	if err != nil {
+		setStatusCondition(objStatus, generation, v1alpha1.ConditionReady, metav1.ConditionFalse, failureReason(err), err.Error())
+	} else {
+		setStatusCondition(objStatus, generation, v1alpha1.ConditionReady, metav1.ConditionTrue, v1alpha1.ReasonReconciled, "")
+	}
+	objStatus.ObservedGeneration = generation
+	objStatus.LastEvaluationTime = metav1.NewTime(time.Now().In(time.UTC))
+}
+
+// updateStatus persists objStatus, the status of obj, unless it only differs from original by its
+// LastEvaluationTime, which changes on every reconciliation and is not worth a write on its own.
+func updateStatus(
+	ctx context.Context,
+	c client.Client,
+	obj client.Object,
+	original, objStatus *v1alpha1.DynamicVerticalPodAutoscalerStatus,
+) error {
+	unchanged := original.DeepCopy()
+	unchanged.LastEvaluationTime = objStatus.LastEvaluationTime
+	if equality.Semantic.DeepEqual(unchanged, objStatus) {
+		return nil
+	}
+	return c.Status().Update(ctx, obj)
+}
+
+// matchedPolicyStatus returns the index and name of the matched policy reported in the status.
+func matchedPolicyStatus(policies []v1alpha1.DynamicVerticalPodAutoscalerPolicy, matchedIndex int) (*int32, string) {
+	if matchedIndex < 0 {
+		return nil, ""
+	}
+	return ptr.To(int32(matchedIndex)), policies[matchedIndex].Name
+}
+
+// policyDisplayName returns a human-readable identifier for a policy.
+func policyDisplayName(index int, policy *v1alpha1.DynamicVerticalPodAutoscalerPolicy) string {
+	if len(policy.Name) > 0 {
+		return fmt.Sprintf("%d (%s)", index, policy.Name)
+	}
+	return fmt.Sprintf("%d", index)
+}
@@ -0,0 +1,968 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	autoscaling "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// policySource is the object declaring the policies evaluated for a target,
+// and owning the VerticalPodAutoscalers created for it.
+type policySource struct {
+	// obj is a DynamicVerticalPodAutoscaler or a ClusterDynamicVerticalPodAutoscaler.
+	obj client.Object
+	// kind is the kind of obj, whose TypeMeta is usually empty.
+	kind     string
+	policies []v1alpha1.DynamicVerticalPodAutoscalerPolicy
+	language v1alpha1.ConditionLanguage
+	// labelKey is the label identifying the VerticalPodAutoscalers created for obj.
+	labelKey string
+	// template is the policy template whose policies were merged into policies, if any.
+	template *v1alpha1.DynamicVerticalPodAutoscalerPolicyTemplate
+	// dryRun is true when the VerticalPodAutoscalers must not be written.
+	dryRun bool
+	// vpaNameTemplate computes the names of the VerticalPodAutoscalers of the selected workloads.
+	vpaNameTemplate string
+	// adoptionPolicy controls whether existing VerticalPodAutoscalers not controlled by obj are adopted.
+	adoptionPolicy v1alpha1.AdoptionPolicy
+	// force takes the ownership of the fields of the VerticalPodAutoscalers owned by other managers.
+	force bool
+	// deletionPolicy controls what happens to the VerticalPodAutoscalers of obj when it is deleted.
+	deletionPolicy v1alpha1.DeletionPolicy
+	// noMatchAction controls what happens to the VerticalPodAutoscaler of a target matched by no policy.
+	noMatchAction v1alpha1.NoMatchAction
+	// defaultVpaSpec is applied to the targets matched by no policy when noMatchAction is Default.
+	defaultVpaSpec *v1alpha1.VpaSpec
+}
+
+// namespacedPolicySource returns the policySource of a DynamicVerticalPodAutoscaler.
+func namespacedPolicySource(obj *v1alpha1.DynamicVerticalPodAutoscaler) *policySource {
+	return &policySource{
+		obj:             obj,
+		kind:            "DynamicVerticalPodAutoscaler",
+		policies:        obj.Spec.Policies,
+		language:        obj.Spec.Language,
+		labelKey:        v1alpha1.DynamicVerticalPodAutoscalerLabel,
+		dryRun:          obj.Spec.Mode == v1alpha1.ModeDryRun,
+		vpaNameTemplate: obj.Spec.VPANameTemplate,
+		adoptionPolicy:  obj.Spec.AdoptionPolicy,
+		force:           obj.Spec.Force,
+		deletionPolicy:  obj.Spec.DeletionPolicy,
+		noMatchAction:   obj.Spec.NoMatchAction,
+		defaultVpaSpec:  obj.Spec.DefaultVpaSpec,
+	}
+}
+
+// programKey returns the key of the compiled condition of the policy at index, or of its computed field
+// at path if it is not empty, identifying the revisions of obj and of its policy template, if any.
+func (src *policySource) programKey(index int, path string) programKey {
+	key := programKey{
+		uid:        src.obj.GetUID(),
+		generation: src.obj.GetGeneration(),
+		index:      index,
+		field:      path,
+	}
+	if src.template != nil {
+		key.templateUID = src.template.UID
+		key.templateGeneration = src.template.Generation
+	}
+	return key
+}
+
+// clusterPolicySource returns the policySource of a ClusterDynamicVerticalPodAutoscaler.
+func clusterPolicySource(obj *v1alpha1.ClusterDynamicVerticalPodAutoscaler) *policySource {
+	return &policySource{
+		obj:             obj,
+		kind:            "ClusterDynamicVerticalPodAutoscaler",
+		policies:        obj.Spec.Policies,
+		language:        obj.Spec.Language,
+		labelKey:        v1alpha1.ClusterDynamicVerticalPodAutoscalerLabel,
+		dryRun:          obj.Spec.Mode == v1alpha1.ModeDryRun,
+		vpaNameTemplate: obj.Spec.VPANameTemplate,
+		adoptionPolicy:  obj.Spec.AdoptionPolicy,
+		force:           obj.Spec.Force,
+		deletionPolicy:  obj.Spec.DeletionPolicy,
+		noMatchAction:   obj.Spec.NoMatchAction,
+		defaultVpaSpec:  obj.Spec.DefaultVpaSpec,
+	}
+}
+
+// targetReconciler evaluates policies for targets and synchronises their VerticalPodAutoscalers.
+// It is shared by the DynamicVerticalPodAutoscaler and ClusterDynamicVerticalPodAutoscaler reconcilers.
+type targetReconciler struct {
+	client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	programs *programCache
+	// historySize is the maximum number of policy transitions kept in the status.
+	historySize int
+	// maxTargets is the maximum number of targets of a targetSelector which are managed.
+	maxTargets int
+	// podsIndexed is true when the indexes of SetupPodIndexes are registered.
+	// The pods variable is empty otherwise.
+	podsIndexed bool
+	// cluster summarises the cluster for the cluster variable. The variable is nil when unset.
+	cluster *ClusterFacts
+	// workloads are the allowed kinds of targets, and describe how their pod templates and replicas are read.
+	workloads workloadKinds
+}
+
+// targetResult is the outcome of the reconciliation of a single target.
+type targetResult struct {
+	// matchedIndex is the index of the matched policy, or -1 if no policy matched.
+	matchedIndex int
+	// skipped is true when the matched policy skips the reconciliation.
+	skipped bool
+	// syncReason is the reason reported on the VPASynced condition, if the VPA was synchronised.
+	syncReason string
+	// dryRun reports the changes that would have been made to the VPA in DryRun mode.
+	dryRun *v1alpha1.DryRunStatus
+	// history is the history of the matched policies, including the transition to the matched policy.
+	history []v1alpha1.PolicyTransition
+	// pending is the transition to the policy matched by the conditions, when it is held back
+	// by the minDwell of the matched policy or its own enterAfter.
+	pending *v1alpha1.PendingTransition
+	// nextBoundary is the next start or end of the time windows evaluated, if any.
+	nextBoundary time.Time
+	// conflict is the reason why an existing VerticalPodAutoscaler was not adopted or not applied,
+	// when syncReason is ReasonAdoptionConflict or ReasonFieldConflict.
+	conflict string
+	// noMatchAction is the action applied when no policy matched.
+	noMatchAction v1alpha1.NoMatchAction
+}
+
+// noMatchActed returns true when a noMatchAction other than Error was applied.
+func (res targetResult) noMatchActed() bool {
+	return len(res.noMatchAction) > 0 && res.noMatchAction != v1alpha1.NoMatchActionError
+}
+
+// vpaWritten returns true when the VerticalPodAutoscaler was created, updated or adopted.
+func (res targetResult) vpaWritten() bool {
+	switch res.syncReason {
+	case v1alpha1.ReasonCreated, v1alpha1.ReasonUpdated, v1alpha1.ReasonAdopted:
+		return true
+	}
+	return false
+}
+
+// errNoMatchingPolicy is returned when no policy matched a target.
+var errNoMatchingPolicy = errors.New("no matching policy found")
+
+// reconcileTarget evaluates the policies of src for a target, and synchronises the VerticalPodAutoscaler vpaKey.
+// The target may be nil if it does not exist. history is the history of the policies matched
+// by the previous evaluations, exposed to the conditions and used to report policy transitions.
+// pending is the transition held back by the previous evaluation, if any.
+func (t *targetReconciler) reconcileTarget(
+	ctx context.Context,
+	src *policySource,
+	targetRef *autoscaling.CrossVersionObjectReference,
+	vpaTarget *unstructured.Unstructured,
+	vpaKey client.ObjectKey,
+	history []v1alpha1.PolicyTransition,
+	pending *v1alpha1.PendingTransition,
+) (targetResult, error) {
+	logger := log.FromContext(ctx)
+	res := targetResult{matchedIndex: -1, history: history}
+
+	var existingVpa = &vpa.VerticalPodAutoscaler{}
+	vpaExists := true
+	if err := t.Get(ctx, vpaKey, existingVpa); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return res, err
+		}
+		vpaExists = false
+	}
+
+	env, err := t.getProgramEnv(ctx, src.obj, existingVpa, vpaTarget, history)
+	if err != nil {
+		return res, err
+	}
+
+	matchedIndex, err := t.evaluatePolicies(ctx, src, env)
+	res.nextBoundary = nextWindowBoundary(env)
+	if err != nil {
+		var exprErr *expressionError
+		if errors.As(err, &exprErr) {
+			recordPolicyError(src.obj, exprErr.reason)
+			t.targetEvent(src, vpaTarget, corev1.EventTypeWarning, exprErr.reason, err.Error())
+			return res, withReason(exprErr.reason, err)
+		}
+		return res, err
+	}
+
+	if matchedIndex < 0 {
+		if noMatchActionOrDefault(src.noMatchAction) == v1alpha1.NoMatchActionError {
+			res.noMatchAction = v1alpha1.NoMatchActionError
+			recordPolicyError(src.obj, v1alpha1.ReasonNoMatch)
+			t.targetEvent(src, vpaTarget, corev1.EventTypeWarning, v1alpha1.ReasonNoMatch, "no policy condition evaluated to true")
+			return res, withReason(v1alpha1.ReasonNoMatch, errNoMatchingPolicy)
+		}
+		err := t.reconcileNoMatch(ctx, src, targetRef, vpaTarget, vpaKey, existingVpa, vpaExists, &res)
+		return res, err
+	}
+	recordPolicyMatch(src.obj, matchedIndex, src.policies[matchedIndex].Name)
+
+	now := time.Now()
+	heldIndex, nextPending := holdPolicy(src.policies, history, pending, matchedIndex, now)
+	if nextPending != nil {
+		if pending == nil || pending.To != nextPending.To {
+			t.targetEvent(src, vpaTarget, corev1.EventTypeNormal, v1alpha1.ReasonTransitionPending,
+				fmt.Sprintf("policy %s matched, keeping policy %s until %s",
+					policyDisplayName(matchedIndex, &src.policies[matchedIndex]),
+					policyDisplayName(heldIndex, &src.policies[heldIndex]),
+					nextPending.EligibleAt.Format(time.RFC3339)))
+		}
+		logger.V(5).Info("Holding policy", "matched", matchedIndex, "held", heldIndex, "eligibleAt", nextPending.EligibleAt)
+		matchedIndex = heldIndex
+	}
+	res.matchedIndex = matchedIndex
+	res.pending = nextPending
+
+	matchedPolicy := &src.policies[matchedIndex]
+	lastMatched := lastMatchedIndex(history)
+	policyChanged := lastMatched == nil || int(*lastMatched) != matchedIndex
+	var liveVPA *vpa.VerticalPodAutoscaler
+	if vpaExists {
+		liveVPA = existingVpa
+	}
+	if matchedPolicy.Skip {
+		if policyChanged {
+			t.targetEvent(src, vpaTarget, corev1.EventTypeNormal, reasonPolicyChanged,
+				policyTransitionMessage(src.policies, lastMatched, matchedIndex, liveVPA, nil))
+		}
+		res.history = recordTransition(history, matchedIndex, "", now, t.historySize)
+		logger.V(5).Info("Skipping reconciliation")
+		res.skipped = true
+		return res, nil
+	}
+
+	logger.V(5).Info("Reconciling",
+		"policy", matchedPolicy.Condition,
+	)
+
+	wantVpaSpec, err := makeVpaSpec(targetRef, &matchedPolicy.VpaSpec, t.computeFunc(src, matchedIndex, env))
+	res.nextBoundary = nextWindowBoundary(env)
+	if err != nil {
+		var exprErr *expressionError
+		if !errors.As(err, &exprErr) {
+			exprErr = &expressionError{reason: v1alpha1.ReasonRuntimeError, index: matchedIndex, err: err}
+		}
+		recordPolicyError(src.obj, exprErr.reason)
+		t.targetEvent(src, vpaTarget, corev1.EventTypeWarning, exprErr.reason, exprErr.Error())
+		return res, withReason(exprErr.reason, exprErr)
+	}
+
+	if policyChanged {
+		t.targetEvent(src, vpaTarget, corev1.EventTypeNormal, reasonPolicyChanged,
+			policyTransitionMessage(src.policies, lastMatched, matchedIndex, liveVPA, &wantVpaSpec))
+	}
+	res.history = recordTransition(history, matchedIndex, specHash(&wantVpaSpec), now, t.historySize)
+
+	err = t.writeVPA(ctx, src, vpaTarget, vpaKey, existingVpa, vpaExists, wantVpaSpec, matchedIndex, &res)
+	return res, err
+}
+
+// writeVPA writes wantVpaSpec, computed by the policy at policyIndex or by the defaultVpaSpec when it is -1,
+// to the VerticalPodAutoscaler vpaKey, unless src is in DryRun mode. The outcome is reported in res.
+// An error with the ReasonAdoptionConflict or ReasonFieldConflict reason is returned when it cannot be
+// adopted or applied.
+func (t *targetReconciler) writeVPA(
+	ctx context.Context,
+	src *policySource,
+	vpaTarget *unstructured.Unstructured,
+	vpaKey client.ObjectKey,
+	existingVpa *vpa.VerticalPodAutoscaler,
+	vpaExists bool,
+	wantVpaSpec vpa.VerticalPodAutoscalerSpec,
+	policyIndex int,
+	res *targetResult,
+) error {
+	if vpaExists {
+		if conflict := adoptionConflict(src, existingVpa); len(conflict) > 0 {
+			t.targetEvent(src, vpaTarget, corev1.EventTypeWarning, v1alpha1.ReasonAdoptionConflict, conflict)
+			res.syncReason = v1alpha1.ReasonAdoptionConflict
+			res.conflict = conflict
+			return withReason(v1alpha1.ReasonAdoptionConflict, errors.New(conflict))
+		}
+	}
+
+	if src.dryRun {
+		dryRun, err := t.dryRunVPA(ctx, src, vpaKey, existingVpa, vpaExists, wantVpaSpec)
+		if apierrors.IsConflict(err) {
+			return t.fieldConflict(src, vpaTarget, vpaKey, err, res)
+		}
+		if err != nil {
+			return withReason(v1alpha1.ReasonSyncFailed, err)
+		}
+		res.dryRun = dryRun
+		res.syncReason = v1alpha1.ReasonUpToDate
+		if res.dryRun.Action != v1alpha1.DryRunActionNone {
+			res.syncReason = v1alpha1.ReasonDryRun
+			t.recordDryRun(src, vpaKey, policyIndex, res.dryRun)
+		}
+		return nil
+	}
+
+	syncReason, err := t.syncVPA(ctx, src, vpaKey, existingVpa, vpaExists, wantVpaSpec)
+	if syncReason == v1alpha1.ReasonFieldConflict {
+		return t.fieldConflict(src, vpaTarget, vpaKey, err, res)
+	}
+	res.syncReason = syncReason
+	if err != nil {
+		t.targetEvent(src, vpaTarget, corev1.EventTypeWarning, v1alpha1.ReasonSyncFailed,
+			fmt.Sprintf("failed to synchronise VerticalPodAutoscaler %s: %v", vpaKey, err))
+		return withReason(v1alpha1.ReasonSyncFailed, err)
+	}
+	switch res.syncReason {
+	case v1alpha1.ReasonCreated:
+		t.targetEvent(src, vpaTarget, corev1.EventTypeNormal, reasonVPACreated,
+			fmt.Sprintf("created VerticalPodAutoscaler %s", vpaKey))
+	case v1alpha1.ReasonUpdated:
+		t.targetEvent(src, vpaTarget, corev1.EventTypeNormal, reasonVPAUpdated,
+			fmt.Sprintf("updated VerticalPodAutoscaler %s", vpaKey))
+	case v1alpha1.ReasonAdopted:
+		t.targetEvent(src, vpaTarget, corev1.EventTypeNormal, reasonVPAAdopted,
+			fmt.Sprintf("adopted VerticalPodAutoscaler %s", vpaKey))
+	}
+	setUpdateMode(src.obj, vpaKey, &wantVpaSpec)
+	return nil
+}
+
+// fieldConflict reports in res that the fields of the VerticalPodAutoscaler vpaKey could not be applied
+// because of err, as they are owned by another manager, and returns the error with the ReasonFieldConflict reason.
+func (t *targetReconciler) fieldConflict(
+	src *policySource,
+	vpaTarget *unstructured.Unstructured,
+	vpaKey client.ObjectKey,
+	err error,
+	res *targetResult,
+) error {
+	res.syncReason = v1alpha1.ReasonFieldConflict
+	res.conflict = fmt.Sprintf("VerticalPodAutoscaler %s: %v", vpaKey, err)
+	t.targetEvent(src, vpaTarget, corev1.EventTypeWarning, v1alpha1.ReasonFieldConflict, res.conflict)
+	return withReason(v1alpha1.ReasonFieldConflict, errors.New(res.conflict))
+}
+
+// evaluatePolicies returns the index of the first policy whose condition evaluates to true
+// during a window of its schedule, or -1 if none matched.
+func (t *targetReconciler) evaluatePolicies(
+	ctx context.Context,
+	src *policySource,
+	env map[string]interface{},
+) (int, error) {
+	logger := log.FromContext(ctx)
+
+	for i, policy := range src.policies {
+
+		logger.V(5).Info("Checking policy",
+			"condition", policy.Condition,
+			"index", i,
+		)
+
+		if policy.Schedule != nil {
+			window, err := policyWindow(policy.Schedule)
+			if err != nil {
+				return -1, &expressionError{reason: v1alpha1.ReasonRuntimeError, index: i, err: fmt.Errorf("schedule: %w", err)}
+			}
+			in, boundary := window.contains(time.Now())
+			windows, _ := env[windowsVariable].(map[string]interface{})
+			recordBoundary(windows, boundary)
+			if !in {
+				continue
+			}
+		}
+
+		if len(policy.Condition) == 0 {
+			// When condition is empty, this evaluates to true
+			return i, nil
+		}
+
+		language := conditionLanguage(src.language, &policy)
+		compiled, err := t.programs.getOrCompile(
+			src.programKey(i, ""),
+			func() (program, error) {
+				return compileCondition(language, policy.Condition, env)
+			},
+		)
+		if err != nil {
+			return -1, &expressionError{reason: v1alpha1.ReasonCompileError, index: i, err: err}
+		}
+
+		start := time.Now()
+		matched, err := compiled.run(env)
+		observeEvaluation(string(language), start)
+		if err != nil {
+			return -1, &expressionError{reason: v1alpha1.ReasonRuntimeError, index: i, err: err}
+		}
+		if matched {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// computeFunc returns the computeFunc evaluating the expressions of the policy at index against env.
+func (t *targetReconciler) computeFunc(src *policySource, index int, env map[string]interface{}) computeFunc {
+	policy := &src.policies[index]
+	language := conditionLanguage(src.language, policy)
+	return func(path, expression string) (interface{}, error) {
+		compiled, err := t.programs.getOrCompileValue(
+			src.programKey(index, path),
+			func() (valueProgram, error) {
+				return compileValue(language, expression, env)
+			},
+		)
+		if err != nil {
+			return nil, &expressionError{reason: v1alpha1.ReasonCompileError, index: index, err: fmt.Errorf("%s: %w", path, err)}
+		}
+		start := time.Now()
+		value, err := compiled.eval(env)
+		observeEvaluation(string(language), start)
+		if err != nil {
+			return nil, &expressionError{reason: v1alpha1.ReasonRuntimeError, index: index, err: fmt.Errorf("%s: %w", path, err)}
+		}
+		return value, nil
+	}
+}
+
+// syncVPA applies wantVpaSpec to the VerticalPodAutoscaler vpaKey owned by src with server-side apply,
+// so that the controller only owns the fields specified by the policy, and the fields set by other
+// managers are kept. An existing VerticalPodAutoscaler not controlled by src is adopted first, which
+// must have been allowed by adoptionConflict. It returns the reason to report on the VPASynced condition.
+// When the fields of wantVpaSpec are owned by another manager and src does not force the apply,
+// it returns ReasonFieldConflict along with the conflict.
+func (t *targetReconciler) syncVPA(
+	ctx context.Context,
+	src *policySource,
+	vpaKey client.ObjectKey,
+	foundVPA *vpa.VerticalPodAutoscaler,
+	vpaExists bool,
+	wantVpaSpec vpa.VerticalPodAutoscalerSpec,
+) (string, error) {
+	logger := log.FromContext(ctx)
+
+	adopted := vpaExists && !metav1.IsControlledBy(foundVPA, src.obj)
+	if adopted {
+		// The controller of the VerticalPodAutoscaler is replaced with an update,
+		// as it may be owned by another manager.
+		logger.Info("Adopting VerticalPodAutoscaler", "vpa", vpaKey.Name, "adoptionPolicy", src.adoptionPolicy)
+		if err := adopt(src, foundVPA, t.scheme); err != nil {
+			return "", err
+		}
+		if err := t.Update(ctx, foundVPA, client.FieldOwner(fieldManager)); err != nil {
+			return "", err
+		}
+	}
+
+	if vpaExists && !adopted && !appliedBy(foundVPA, fieldManager) {
+		if err := t.upgradeManagedFields(ctx, foundVPA); err != nil {
+			return "", err
+		}
+	}
+
+	applied, err := vpaApplyConfiguration(src, vpaKey, wantVpaSpec, t.scheme)
+	if err != nil {
+		return "", err
+	}
+
+	// The fields of an adopted VerticalPodAutoscaler set by the policy are taken over from their previous
+	// managers, e.g. Helm, which would otherwise conflict with the apply.
+	if err := t.Patch(ctx, applied, client.Apply, applyOptions(src, adopted)...); err != nil {
+		if apierrors.IsConflict(err) {
+			return v1alpha1.ReasonFieldConflict, err
+		}
+		return "", err
+	}
+
+	switch {
+	case !vpaExists:
+		recordVPAOperation(src.obj, "create")
+		return v1alpha1.ReasonCreated, nil
+	case adopted:
+		recordVPAOperation(src.obj, "adopt")
+		return v1alpha1.ReasonAdopted, nil
+	case applied.GetResourceVersion() == foundVPA.ResourceVersion:
+		// Applying unchanged fields does not modify the object.
+		logger.V(5).Info("No update needed")
+		return v1alpha1.ReasonUpToDate, nil
+	}
+	recordVPAOperation(src.obj, "update")
+	return v1alpha1.ReasonUpdated, nil
+}
+
+// vpaApplyConfiguration returns the VerticalPodAutoscaler vpaKey controlled by src with the spec wantVpaSpec,
+// as applied by syncVPA. Only the fields set by the policy are included, so that the controller does
+// not own the others.
+func vpaApplyConfiguration(
+	src *policySource,
+	vpaKey client.ObjectKey,
+	wantVpaSpec vpa.VerticalPodAutoscalerSpec,
+	scheme *runtime.Scheme,
+) (*unstructured.Unstructured, error) {
+	want := &vpa.VerticalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: vpa.SchemeGroupVersion.String(),
+			Kind:       "VerticalPodAutoscaler",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      vpaKey.Name,
+			Namespace: vpaKey.Namespace,
+			Labels:    map[string]string{src.labelKey: src.obj.GetName()},
+		},
+		Spec: wantVpaSpec,
+	}
+	if err := controllerutil.SetControllerReference(src.obj, want, scheme); err != nil {
+		return nil, err
+	}
+
+	// The empty status and creation timestamp of the typed object must not be applied.
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(want)
+	if err != nil {
+		return nil, err
+	}
+	applied := &unstructured.Unstructured{Object: content}
+	unstructured.RemoveNestedField(applied.Object, "status")
+	unstructured.RemoveNestedField(applied.Object, "metadata", "creationTimestamp")
+	return applied, nil
+}
+
+// applyOptions returns the options of the applies of the VerticalPodAutoscalers of src,
+// which take the ownership of the conflicting fields when src forces it or takeOver is true.
+func applyOptions(src *policySource, takeOver bool) []client.PatchOption {
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if src.force || takeOver {
+		opts = append(opts, client.ForceOwnership)
+	}
+	return opts
+}
+
+// upgradeManagedFields moves the fields of foundVPA written with updates by previous versions of the
+// controller to the fieldManager of its applies, so that the first apply takes them over, and removes
+// the ones that the policy does not set.
+func (t *targetReconciler) upgradeManagedFields(ctx context.Context, foundVPA *vpa.VerticalPodAutoscaler) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(foundVPA, sets.New(legacyFieldManager, fieldManager), fieldManager)
+	if err != nil || patch == nil {
+		return err
+	}
+	log.FromContext(ctx).Info("Upgrading the managed fields of VerticalPodAutoscaler", "vpa", foundVPA.Name)
+	return t.Patch(ctx, foundVPA, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// appliedBy returns true if obj was applied by manager with server-side apply.
+func appliedBy(obj client.Object, manager string) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == manager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return true
+		}
+	}
+	return false
+}
+
+// getProgramEnv returns the environment available in the conditions
+func (t *targetReconciler) getProgramEnv(
+	ctx context.Context,
+	obj client.Object,
+	existingVpa *vpa.VerticalPodAutoscaler,
+	vpaTarget *unstructured.Unstructured,
+	history []v1alpha1.PolicyTransition,
+) (map[string]interface{}, error) {
+
+	var objUnstructured = &unstructured.Unstructured{}
+	if err := t.scheme.Convert(obj, objUnstructured, nil); err != nil {
+		return nil, err
+	}
+
+	var vpaUnstructured = &unstructured.Unstructured{}
+	if existingVpa != nil {
+		if err := t.scheme.Convert(existingVpa, vpaUnstructured, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var target map[string]interface{}
+	if vpaTarget != nil {
+		var err error
+		if target, err = t.targetValue(ctx, vpaTarget); err != nil {
+			return nil, err
+		}
+	}
+
+	env := programEnv(target, vpaUnstructured.Object, objUnstructured.Object)
+	setHistory(env, history)
+
+	namespace := obj.GetNamespace()
+	if vpaTarget != nil {
+		namespace = vpaTarget.GetNamespace()
+	}
+	if len(namespace) > 0 {
+		// The Namespace is read as a typed object, so that it is served from the cache.
+		var ns corev1.Namespace
+		if err := t.Get(ctx, client.ObjectKey{Name: namespace}, &ns); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		} else if err == nil {
+			var nsUnstructured = &unstructured.Unstructured{}
+			if err := t.scheme.Convert(&ns, nsUnstructured, nil); err != nil {
+				return nil, err
+			}
+			env["namespace"] = nsUnstructured.Object
+		}
+	}
+	if t.cluster != nil {
+		cluster, err := t.cluster.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		env["cluster"] = cluster
+	}
+	if vpaTarget != nil && t.podsIndexed {
+		pods, err := t.listTargetPods(ctx, vpaTarget)
+		if err != nil {
+			return nil, err
+		}
+		setPods(env, pods, time.Now())
+	}
+	return env, nil
+}
+
+// reconcileSelectedTargets reconciles one VerticalPodAutoscaler for every selected target,
+// and reports the outcome in status. It returns the keys of the VerticalPodAutoscalers that are
+// still wanted, which are also returned when some targets failed, and the next boundary of the
+// time windows evaluated for any target.
+// Only the first maxTargets targets are managed, so that the status stays within the size limit of
+// the objects: the targets listed in status are kept first, so that their history is not lost, and
+// the VerticalPodAutoscalers of the other targets are left unchanged.
+func (t *targetReconciler) reconcileSelectedTargets(
+	ctx context.Context,
+	src *policySource,
+	status *v1alpha1.DynamicVerticalPodAutoscalerStatus,
+	targets []unstructured.Unstructured,
+) (sets.Set[client.ObjectKey], time.Time, error) {
+	var (
+		errs         []error
+		statuses     = make([]v1alpha1.TargetStatus, 0, len(targets))
+		wanted       = sets.New[client.ObjectKey]()
+		written      bool
+		exprErrors   int
+		noMatches    int
+		noMatchActed int
+		syncErrors   int
+		conflicts    int
+		lastConflict string
+		dryRuns      int
+		boundary     time.Time
+		lastExprErr  *expressionError
+		generation   = src.obj.GetGeneration()
+		clusterWide  = len(src.obj.GetNamespace()) == 0
+		previous     = make(map[targetStatusKey]*v1alpha1.TargetStatus, len(status.Targets))
+		managed      = len(targets)
+	)
+	vpaNameTemplate, err := parseVPANameTemplate(src.vpaNameTemplate)
+	if err != nil {
+		return wanted, boundary, withReason(v1alpha1.ReasonInvalidSpec, fmt.Errorf("vpaNameTemplate: %w", err))
+	}
+	for i := range status.Targets {
+		previous[targetStatusKeyOf(&status.Targets[i])] = &status.Targets[i]
+	}
+	targets = managedTargetsFirst(targets, status.Targets, clusterWide)
+	if maxTargets := t.maxTargetsOrDefault(); managed > maxTargets {
+		managed = maxTargets
+	}
+	for i := range targets {
+		target := &targets[i]
+		gvk := target.GroupVersionKind()
+		targetRef := &autoscaling.CrossVersionObjectReference{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Name:       target.GetName(),
+		}
+		key := newTargetStatusKey(target, clusterWide)
+		vpaName, err := selectedVPAName(vpaNameTemplate, vpaNameData{
+			Name:       src.obj.GetName(),
+			Namespace:  target.GetNamespace(),
+			Kind:       gvk.Kind,
+			TargetName: target.GetName(),
+		})
+		if i >= managed {
+			if err == nil {
+				wanted.Insert(client.ObjectKey{Namespace: target.GetNamespace(), Name: vpaName})
+			}
+			continue
+		}
+		if err != nil {
+			err = withReason(v1alpha1.ReasonInvalidSpec, fmt.Errorf("vpaNameTemplate: %w", err))
+			targetStatus := v1alpha1.TargetStatus{
+				APIVersion: targetRef.APIVersion,
+				Kind:       targetRef.Kind,
+				Name:       targetRef.Name,
+				Namespace:  key.namespace,
+				Message:    err.Error(),
+			}
+			if prev, ok := previous[key]; ok {
+				targetStatus.History, targetStatus.PendingTransition = prev.History, prev.PendingTransition
+			}
+			statuses = append(statuses, targetStatus)
+			if clusterWide {
+				errs = append(errs, fmt.Errorf("%s %s/%s: %w", targetRef.Kind, target.GetNamespace(), targetRef.Name, err))
+			} else {
+				errs = append(errs, fmt.Errorf("%s %s: %w", targetRef.Kind, targetRef.Name, err))
+			}
+			continue
+		}
+		vpaKey := client.ObjectKey{Namespace: target.GetNamespace(), Name: vpaName}
+		wanted.Insert(vpaKey)
+
+		logger := log.FromContext(ctx).WithValues("target", targetRef)
+		if clusterWide {
+			logger = logger.WithValues("targetNamespace", target.GetNamespace())
+		}
+		var history []v1alpha1.PolicyTransition
+		var pending *v1alpha1.PendingTransition
+		if prev, ok := previous[key]; ok {
+			history, pending = prev.History, prev.PendingTransition
+		}
+		res, err := t.reconcileTarget(log.IntoContext(ctx, logger), src, targetRef, target, vpaKey, history, pending)
+		written = written || res.vpaWritten()
+		if !res.nextBoundary.IsZero() && (boundary.IsZero() || res.nextBoundary.Before(boundary)) {
+			boundary = res.nextBoundary
+		}
+		switch res.syncReason {
+		case v1alpha1.ReasonDryRun:
+			dryRuns++
+		case v1alpha1.ReasonAdoptionConflict, v1alpha1.ReasonFieldConflict:
+			conflicts++
+			lastConflict = res.syncReason
+		}
+
+		targetStatus := v1alpha1.TargetStatus{
+			APIVersion:        targetRef.APIVersion,
+			Kind:              targetRef.Kind,
+			Name:              targetRef.Name,
+			VPAName:           vpaKey.Name,
+			DryRun:            res.dryRun,
+			History:           res.history,
+			PendingTransition: res.pending,
+		}
+		if clusterWide {
+			targetStatus.Namespace = target.GetNamespace()
+		}
+		targetStatus.MatchedPolicyIndex, targetStatus.MatchedPolicyName = matchedPolicyStatus(src.policies, res.matchedIndex)
+		targetStatus.NoMatchAction = res.noMatchAction
+		targetStatus.Message = res.conflict
+		if res.noMatchActed() {
+			noMatchActed++
+		}
+		if err != nil {
+			targetStatus.Message = err.Error()
+			if clusterWide {
+				errs = append(errs, fmt.Errorf("%s %s/%s: %w", targetRef.Kind, target.GetNamespace(), targetRef.Name, err))
+			} else {
+				errs = append(errs, fmt.Errorf("%s %s: %w", targetRef.Kind, targetRef.Name, err))
+			}
+
+			var exprErr *expressionError
+			var rErr *reconcileError
+			switch {
+			case errors.As(err, &exprErr):
+				exprErrors++
+				lastExprErr = exprErr
+			case errors.Is(err, errNoMatchingPolicy):
+				noMatches++
+			case errors.As(err, &rErr) && rErr.reason == v1alpha1.ReasonSyncFailed:
+				syncErrors++
+			}
+		}
+		statuses = append(statuses, targetStatus)
+	}
+	status.Targets = statuses
+	if written {
+		status.VPALastUpdateTime = metav1.NewTime(time.Now().In(time.UTC))
+	}
+	if managed < len(targets) {
+		errs = append(errs, withReason(v1alpha1.ReasonTooManyTargets,
+			fmt.Errorf("%d workloads match the targetSelector, only %d are managed", len(targets), managed)))
+	}
+
+	if len(targets) == 0 {
+		t.event(src.obj, corev1.EventTypeWarning, v1alpha1.ReasonTargetNotFound, "no workload matches the targetSelector")
+		setStatusCondition(status, generation, v1alpha1.ConditionTargetFound, metav1.ConditionFalse, v1alpha1.ReasonTargetNotFound,
+			"no workload matches the targetSelector")
+	} else {
+		setStatusCondition(status, generation, v1alpha1.ConditionTargetFound, metav1.ConditionTrue, v1alpha1.ReasonTargetFound,
+			fmt.Sprintf("%d workloads match the targetSelector", len(targets)))
+	}
+
+	if lastExprErr != nil {
+		setStatusCondition(status, generation, v1alpha1.ConditionExpressionError, metav1.ConditionTrue, lastExprErr.reason,
+			fmt.Sprintf("%d of %d targets failed to evaluate: %v", exprErrors, managed, lastExprErr))
+	} else {
+		setStatusCondition(status, generation, v1alpha1.ConditionExpressionError, metav1.ConditionFalse, v1alpha1.ReasonNoError, "")
+	}
+
+	if unmatched := exprErrors + noMatches + noMatchActed; unmatched > 0 {
+		message := fmt.Sprintf("%d of %d targets did not match any policy", unmatched, managed)
+		if noMatchActed > 0 {
+			message += fmt.Sprintf(", noMatchAction %s applied to %d", noMatchActionOrDefault(src.noMatchAction), noMatchActed)
+		}
+		setStatusCondition(status, generation, v1alpha1.ConditionPolicyMatched, metav1.ConditionFalse, v1alpha1.ReasonNoMatch, message)
+	} else {
+		setStatusCondition(status, generation, v1alpha1.ConditionPolicyMatched, metav1.ConditionTrue, v1alpha1.ReasonMatched,
+			fmt.Sprintf("%d targets matched a policy", managed))
+	}
+
+	switch {
+	case syncErrors > 0:
+		setStatusCondition(status, generation, v1alpha1.ConditionVPASynced, metav1.ConditionFalse, v1alpha1.ReasonSyncFailed,
+			fmt.Sprintf("%d of %d VerticalPodAutoscalers failed to synchronise", syncErrors, managed))
+	case conflicts > 0:
+		setStatusCondition(status, generation, v1alpha1.ConditionVPASynced, metav1.ConditionFalse, lastConflict,
+			fmt.Sprintf("%d of %d VerticalPodAutoscalers conflict with other objects or field managers", conflicts, managed))
+	case dryRuns > 0:
+		setStatusCondition(status, generation, v1alpha1.ConditionVPASynced, metav1.ConditionFalse, v1alpha1.ReasonDryRun,
+			fmt.Sprintf("%d of %d VerticalPodAutoscalers would be written in DryRun mode", dryRuns, managed))
+	default:
+		setStatusCondition(status, generation, v1alpha1.ConditionVPASynced, metav1.ConditionTrue, v1alpha1.ReasonUpToDate, "")
+	}
+
+	return wanted, boundary, errors.Join(errs...)
+}
+
+// targetStatusKey identifies the target of a TargetStatus.
+// The namespace is only set for the targets of ClusterDynamicVerticalPodAutoscalers.
+type targetStatusKey struct {
+	apiVersion, kind, namespace, name string
+}
+
+// newTargetStatusKey returns the targetStatusKey of target.
+func newTargetStatusKey(target *unstructured.Unstructured, clusterWide bool) targetStatusKey {
+	key := targetStatusKey{apiVersion: target.GetAPIVersion(), kind: target.GetKind(), name: target.GetName()}
+	if clusterWide {
+		key.namespace = target.GetNamespace()
+	}
+	return key
+}
+
+// targetStatusKeyOf returns the targetStatusKey of a target listed in a status.
+func targetStatusKeyOf(targetStatus *v1alpha1.TargetStatus) targetStatusKey {
+	return targetStatusKey{targetStatus.APIVersion, targetStatus.Kind, targetStatus.Namespace, targetStatus.Name}
+}
+
+// less orders the targetStatusKeys by namespace, kind and name.
+func (k targetStatusKey) less(other targetStatusKey) bool {
+	if k.namespace != other.namespace {
+		return k.namespace < other.namespace
+	}
+	if k.kind != other.kind {
+		return k.kind < other.kind
+	}
+	if k.name != other.name {
+		return k.name < other.name
+	}
+	return k.apiVersion < other.apiVersion
+}
+
+// managedTargetsFirst returns targets with the targets listed in statuses first, in their order,
+// followed by the other targets sorted by targetStatusKey.
+func managedTargetsFirst(
+	targets []unstructured.Unstructured,
+	statuses []v1alpha1.TargetStatus,
+	clusterWide bool,
+) []unstructured.Unstructured {
+	positions := make(map[targetStatusKey]int, len(statuses))
+	for i := range statuses {
+		positions[targetStatusKeyOf(&statuses[i])] = i
+	}
+	sorted := append([]unstructured.Unstructured(nil), targets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		keyI, keyJ := newTargetStatusKey(&sorted[i], clusterWide), newTargetStatusKey(&sorted[j], clusterWide)
+		positionI, listedI := positions[keyI]
+		positionJ, listedJ := positions[keyJ]
+		switch {
+		case listedI && listedJ:
+			return positionI < positionJ
+		case listedI != listedJ:
+			return listedI
+		}
+		return keyI.less(keyJ)
+	})
+	return sorted
+}
+
+// maxTargetsOrDefault returns the maximum number of targets of a targetSelector which are managed.
+func (t *targetReconciler) maxTargetsOrDefault() int {
+	if t.maxTargets <= 0 {
+		return DefaultMaxTargets
+	}
+	return t.maxTargets
+}
+
+// deleteStaleVPAs deletes the VerticalPodAutoscalers created for src that are not in wanted.
+// The VerticalPodAutoscalers of a ClusterDynamicVerticalPodAutoscaler are searched in all namespaces.
+// Nothing is deleted in DryRun mode.
+func (t *targetReconciler) deleteStaleVPAs(
+	ctx context.Context,
+	src *policySource,
+	wanted sets.Set[client.ObjectKey],
+) error {
+	if src.dryRun {
+		return nil
+	}
+
+	var list vpa.VerticalPodAutoscalerList
+	if err := t.List(ctx, &list,
+		client.InNamespace(src.obj.GetNamespace()),
+		client.MatchingLabels{src.labelKey: src.obj.GetName()},
+	); err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		item := &list.Items[i]
+		if wanted.Has(client.ObjectKeyFromObject(item)) || !metav1.IsControlledBy(item, src.obj) {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting VerticalPodAutoscaler of a target that is no longer selected",
+			"vpa", item.Name, "namespace", item.Namespace)
+		if err := t.Delete(ctx, item); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		recordVPAOperation(src.obj, "delete")
+		deleteUpdateMode(src.obj, client.ObjectKeyFromObject(item))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// DefaultMaxTargets is the default maximum number of targets of a targetSelector which are managed
+// and listed in the status of an object.
+const DefaultMaxTargets = 250
+
+// validateTargetSelector checks the kinds and the label selector of a targetSelector.
+// The kinds must be allowed kinds.
+func validateTargetSelector(selector *v1alpha1.TargetSelector, kinds workloadKinds, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	kindsPath := path.Child("kinds")
+	if len(selector.Kinds) == 0 {
+		errs = append(errs, field.Required(kindsPath, "at least one kind is required"))
+	}
+	for i, kind := range selector.Kinds {
+		if len(kind.Kind) == 0 {
+			errs = append(errs, field.Required(kindsPath.Index(i).Child("kind"), ""))
+		}
+		if _, err := schema.ParseGroupVersion(kind.APIVersion); err != nil || len(kind.APIVersion) == 0 {
+			errs = append(errs, field.Invalid(kindsPath.Index(i).Child("apiVersion"), kind.APIVersion, "must be a valid apiVersion"))
+		}
+	}
+	errs = append(errs, kinds.validateTargetKinds(selector.Kinds, kindsPath)...)
+
+	if selector.Selector == nil {
+		errs = append(errs, field.Required(path.Child("selector"), ""))
+	} else if _, err := metav1.LabelSelectorAsSelector(selector.Selector); err != nil {
+		errs = append(errs, field.Invalid(path.Child("selector"), selector.Selector, err.Error()))
+	}
+	return errs
+}
+
+// reconcileTargetSelector reconciles one VerticalPodAutoscaler for every workload matching the targetSelector,
+// and deletes the VerticalPodAutoscalers of the workloads that no longer match.
+func (r *DynamicVerticalPodAutoscalerReconciler) reconcileTargetSelector(
+	ctx context.Context,
+	obj *v1alpha1.DynamicVerticalPodAutoscaler,
+	src *policySource,
+) (ctrl.Result, error) {
+	obj.Status.MatchedPolicyIndex = nil
+	obj.Status.MatchedPolicyName = ""
+	obj.Status.NoMatchAction = ""
+	obj.Status.DryRun = nil
+	obj.Status.History = nil
+	obj.Status.PendingTransition = nil
+
+	selector, err := metav1.LabelSelectorAsSelector(obj.Spec.TargetSelector.Selector)
+	if err != nil {
+		return ctrl.Result{}, withReason(v1alpha1.ReasonInvalidSpec, err)
+	}
+
+	selected, err := r.listSelectedTargets(ctx, obj, selector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	targets := r.targets()
+	wanted, boundary, err := targets.reconcileSelectedTargets(ctx, src, &obj.Status, selected)
+	if staleErr := targets.deleteStaleVPAs(ctx, src, wanted); staleErr != nil {
+		err = errors.Join(err, staleErr)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	now := time.Now()
+	return boundaryResult(pendingResult(r.defaultResult(), &obj.Status, now), boundary, now), nil
+}
+
+// listSelectedTargets lists the workloads matching the targetSelector of obj.
+func (r *DynamicVerticalPodAutoscalerReconciler) listSelectedTargets(
+	ctx context.Context,
+	obj *v1alpha1.DynamicVerticalPodAutoscaler,
+	selector labels.Selector,
+) ([]unstructured.Unstructured, error) {
+	return listTargets(ctx, r.Client, r.targetWatcher, obj.Spec.TargetSelector.Kinds, obj.Namespace, selector)
+}
+
+// listTargets lists the workloads of the given kinds matching selector in namespace,
+// or in all namespaces if namespace is empty. The kinds are watched with watcher.
+func listTargets(
+	ctx context.Context,
+	c client.Client,
+	watcher *targetWatcher,
+	kinds []v1alpha1.TargetKind,
+	namespace string,
+	selector labels.Selector,
+) ([]unstructured.Unstructured, error) {
+	var targets []unstructured.Unstructured
+	for _, kind := range kinds {
+		gv, err := schema.ParseGroupVersion(kind.APIVersion)
+		if err != nil {
+			return nil, withReason(v1alpha1.ReasonInvalidSpec, err)
+		}
+		gvk := gv.WithKind(kind.Kind)
+		if err := watcher.ensure(ctx, gvk); err != nil {
+			return nil, err
+		}
+
+		var list unstructured.UnstructuredList
+		list.SetGroupVersionKind(gv.WithKind(kind.Kind + "List"))
+		if err := c.List(ctx, &list,
+			client.InNamespace(namespace),
+			client.MatchingLabelsSelector{Selector: selector},
+		); err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			item.SetGroupVersionKind(gvk)
+			targets = append(targets, item)
+		}
+	}
+	return targets, nil
+}
+
+// defaultVPANameTemplate computes the names of the VerticalPodAutoscalers of the selected workloads
+// when no vpaNameTemplate is set.
+const defaultVPANameTemplate = "{{ .Name }}-{{ lower .Kind }}-{{ .TargetName }}"
+
+// vpaNameData is the data of a vpaNameTemplate.
+type vpaNameData struct {
+	// Name is the name of the DynamicVerticalPodAutoscaler or ClusterDynamicVerticalPodAutoscaler.
+	Name string
+	// Namespace, Kind and TargetName identify the selected workload.
+	Namespace  string
+	Kind       string
+	TargetName string
+}
+
+// parseVPANameTemplate parses a vpaNameTemplate, or defaultVPANameTemplate if text is empty.
+func parseVPANameTemplate(text string) (*template.Template, error) {
+	if len(text) == 0 {
+		text = defaultVPANameTemplate
+	}
+	return template.New("vpaNameTemplate").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"lower": strings.ToLower}).
+		Parse(text)
+}
+
+// validateVPANameTemplate checks that a vpaNameTemplate parses and renders for a sample workload.
+func validateVPANameTemplate(text string, path *field.Path) field.ErrorList {
+	if len(text) == 0 {
+		return nil
+	}
+	tmpl, err := parseVPANameTemplate(text)
+	if err == nil {
+		_, err = selectedVPAName(tmpl, vpaNameData{Name: "example", Namespace: "default", Kind: "Deployment", TargetName: "app"})
+	}
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, text, err.Error())}
+	}
+	return nil
+}
+
+// selectedVPAName returns the name of the VerticalPodAutoscaler managed for a selected workload, rendered with tmpl.
+// Names that would be too long are truncated and suffixed with a hash, to keep them unique.
+func selectedVPAName(tmpl *template.Template, data vpaNameData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	vpaName := b.String()
+	if len(vpaName) > validation.DNS1123SubdomainMaxLength {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(vpaName))
+		suffix := fmt.Sprintf("-%08x", h.Sum32())
+		vpaName = strings.TrimRight(vpaName[:validation.DNS1123SubdomainMaxLength-len(suffix)], "-.") + suffix
+	}
+	if msgs := validation.IsDNS1123Subdomain(vpaName); len(msgs) > 0 {
+		return "", fmt.Errorf("invalid VerticalPodAutoscaler name %q: %s", vpaName, strings.Join(msgs, ", "))
+	}
+	return vpaName, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// errVPACRDMissing is reported while the VerticalPodAutoscaler CRD is not installed.
+var errVPACRDMissing = errors.New("the VerticalPodAutoscaler CRD is not installed")
+
+// VPACRD detects the VerticalPodAutoscaler CRD, which may be installed after the controllers are started.
+// The watches of the VerticalPodAutoscalers owned by the controllers are started once it is installed,
+// as watching a missing kind would prevent the controllers from starting.
+type VPACRD struct {
+	restMapper meta.RESTMapper
+	cache      cache.Cache
+	scheme     *runtime.Scheme
+
+	mu sync.Mutex
+	// pending are the watches started once the CRD is installed.
+	pending []vpaWatch
+}
+
+// vpaWatch is a watch of the VerticalPodAutoscalers controlled by the objects reconciled by a controller.
+type vpaWatch struct {
+	controller controller.Controller
+	handler    handler.EventHandler
+}
+
+// NewVPACRD returns a VPACRD looking up the CRD with the RESTMapper of mgr.
+// It is shared by the controllers of mgr and used as a readiness check.
+func NewVPACRD(mgr ctrl.Manager) *VPACRD {
+	return &VPACRD{
+		restMapper: mgr.GetRESTMapper(),
+		cache:      mgr.GetCache(),
+		scheme:     mgr.GetScheme(),
+	}
+}
+
+// watch registers ctl to be notified of the changes to the VerticalPodAutoscalers controlled by objects of
+// the type of owner, once the CRD is installed.
+func (c *VPACRD) watch(ctl controller.Controller, owner client.Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, vpaWatch{
+		controller: ctl,
+		handler:    handler.EnqueueRequestForOwner(c.scheme, c.restMapper, owner, handler.OnlyControllerOwner()),
+	})
+}
+
+// installed returns true if the VerticalPodAutoscaler CRD is installed, and starts the pending watches
+// the first time it is. A nil VPACRD, used when the reconcilers are not set up with a manager,
+// only looks up the CRD with restMapper.
+func (c *VPACRD) installed(ctx context.Context, restMapper meta.RESTMapper) (bool, error) {
+	if c == nil {
+		return vpaCRDInstalled(restMapper)
+	}
+
+	installed, err := vpaCRDInstalled(c.restMapper)
+	if err != nil || !installed {
+		return installed, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.pending) > 0 {
+		log.FromContext(ctx).Info("Watching VerticalPodAutoscalers")
+		w := c.pending[0]
+		if err := w.controller.Watch(source.Kind(c.cache, &vpa.VerticalPodAutoscaler{}), w.handler); err != nil {
+			return true, err
+		}
+		c.pending = c.pending[1:]
+	}
+	return true, nil
+}
+
+// Check is a healthz.Checker failing while the VerticalPodAutoscaler CRD is not installed.
+func (c *VPACRD) Check(req *http.Request) error {
+	installed, err := c.installed(req.Context(), nil)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		return errVPACRDMissing
+	}
+	return nil
+}
+
+// vpaCRDInstalled returns true if restMapper knows the VerticalPodAutoscaler kind.
+func vpaCRDInstalled(restMapper meta.RESTMapper) (bool, error) {
+	_, err := restMapper.RESTMapping(vpa.SchemeGroupVersion.WithKind("VerticalPodAutoscaler").GroupKind(), vpa.SchemeGroupVersion.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// vpaCRDStatus reports in objStatus whether the VerticalPodAutoscaler CRD is installed.
+// While it is missing, the object is not ready, without being reconciled.
+func vpaCRDStatus(objStatus *v1alpha1.DynamicVerticalPodAutoscalerStatus, generation int64, installed bool) {
+	if installed {
+		setStatusCondition(objStatus, generation, v1alpha1.ConditionVPACRDMissing, metav1.ConditionFalse, v1alpha1.ReasonVPACRDInstalled, "")
+		return
+	}
+	setStatusCondition(objStatus, generation, v1alpha1.ConditionVPACRDMissing, metav1.ConditionTrue,
+		v1alpha1.ReasonVPACRDMissing, errVPACRDMissing.Error())
+	readyStatus(objStatus, generation, withReason(v1alpha1.ReasonVPACRDMissing, errVPACRDMissing))
+}
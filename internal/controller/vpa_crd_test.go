@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// watchCountingController is a controller.Controller counting the watches started on it.
+type watchCountingController struct {
+	controller.Controller
+	watches int
+}
+
+func (c *watchCountingController) Watch(source.Source, handler.EventHandler, ...predicate.Predicate) error {
+	c.watches++
+	return nil
+}
+
+var _ = Describe("VPACRD", func() {
+	var (
+		restMapper *meta.DefaultRESTMapper
+		crd        *VPACRD
+		ctl        *watchCountingController
+	)
+
+	BeforeEach(func() {
+		restMapper = meta.NewDefaultRESTMapper(nil)
+		scheme := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+		crd = &VPACRD{restMapper: restMapper, scheme: scheme}
+		ctl = &watchCountingController{}
+		crd.watch(ctl, &v1alpha1.DynamicVerticalPodAutoscaler{})
+	})
+
+	It("should start the watches once the CRD is installed", func() {
+		installed, err := crd.installed(context.Background(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+		Expect(ctl.watches).To(BeZero())
+		Expect(crd.Check(httptest.NewRequest("GET", "/readyz", nil))).To(MatchError(errVPACRDMissing))
+
+		restMapper.Add(vpa.SchemeGroupVersion.WithKind("VerticalPodAutoscaler"), meta.RESTScopeNamespace)
+		Expect(crd.Check(httptest.NewRequest("GET", "/readyz", nil))).To(Succeed())
+		installed, err = crd.installed(context.Background(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeTrue())
+		Expect(ctl.watches).To(Equal(1))
+	})
+
+	It("should look up the CRD with the given RESTMapper when nil", func() {
+		var nilCRD *VPACRD
+		installed, err := nilCRD.installed(context.Background(), restMapper)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+	})
+
+	It("should report the missing CRD in the status", func() {
+		var objStatus v1alpha1.DynamicVerticalPodAutoscalerStatus
+		vpaCRDStatus(&objStatus, 2, false)
+		Expect(meta.IsStatusConditionTrue(objStatus.Conditions, v1alpha1.ConditionVPACRDMissing)).To(BeTrue())
+		ready := meta.FindStatusCondition(objStatus.Conditions, v1alpha1.ConditionReady)
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(v1alpha1.ReasonVPACRDMissing))
+
+		vpaCRDStatus(&objStatus, 2, true)
+		Expect(meta.IsStatusConditionFalse(objStatus.Conditions, v1alpha1.ConditionVPACRDMissing)).To(BeTrue())
+	})
+})
@@ -0,0 +1,225 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"math"
+
+	autoscaling "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// computeFunc evaluates the expression computing the field at path.
+type computeFunc func(path, expression string) (interface{}, error)
+
+// makeVpaSpec returns the VerticalPodAutoscaler spec of a policy, with the fields computed by its expressions.
+func makeVpaSpec(
+	targetRef *autoscaling.CrossVersionObjectReference,
+	wantSpec *v1alpha1.VpaSpec,
+	compute computeFunc,
+) (vpa.VerticalPodAutoscalerSpec, error) {
+	spec := vpa.VerticalPodAutoscalerSpec{
+		TargetRef:      targetRef,
+		UpdatePolicy:   wantSpec.UpdatePolicy,
+		ResourcePolicy: wantSpec.ResourcePolicy,
+		Recommenders:   wantSpec.Recommenders,
+	}
+
+	exprs := wantSpec.Expressions
+	if exprs == nil {
+		return spec, nil
+	}
+	// The static fields are shared with the policy, they must not be modified.
+	spec.UpdatePolicy = spec.UpdatePolicy.DeepCopy()
+	spec.ResourcePolicy = spec.ResourcePolicy.DeepCopy()
+
+	const exprsPath = "vpaSpec.expressions"
+	if len(exprs.UpdateMode) > 0 {
+		path := exprsPath + ".updateMode"
+		value, err := compute(path, exprs.UpdateMode)
+		if err != nil {
+			return spec, err
+		}
+		mode, err := toUpdateMode(value)
+		if err != nil {
+			return spec, fmt.Errorf("%s: %w", path, err)
+		}
+		if spec.UpdatePolicy == nil {
+			spec.UpdatePolicy = &vpa.PodUpdatePolicy{}
+		}
+		spec.UpdatePolicy.UpdateMode = &mode
+	}
+
+	for i, containerExprs := range exprs.ContainerPolicies {
+		if spec.ResourcePolicy == nil {
+			spec.ResourcePolicy = &vpa.PodResourcePolicy{}
+		}
+		policy := containerPolicy(spec.ResourcePolicy, containerExprs.ContainerName)
+		path := fmt.Sprintf("%s.containerPolicies[%d]", exprsPath, i)
+
+		var err error
+		if policy.MinAllowed, err = computeResources(compute, path+".minAllowed", containerExprs.MinAllowed, policy.MinAllowed); err != nil {
+			return spec, err
+		}
+		if policy.MaxAllowed, err = computeResources(compute, path+".maxAllowed", containerExprs.MaxAllowed, policy.MaxAllowed); err != nil {
+			return spec, err
+		}
+
+		if len(containerExprs.ControlledResources) > 0 {
+			resourcesPath := path + ".controlledResources"
+			value, err := compute(resourcesPath, containerExprs.ControlledResources)
+			if err != nil {
+				return spec, err
+			}
+			resources, err := toResourceNames(value)
+			if err != nil {
+				return spec, fmt.Errorf("%s: %w", resourcesPath, err)
+			}
+			policy.ControlledResources = &resources
+		}
+	}
+
+	return spec, nil
+}
+
+// effectiveUpdateMode returns the updateMode of spec, defaulting to Auto like the VerticalPodAutoscaler.
+func effectiveUpdateMode(spec *vpa.VerticalPodAutoscalerSpec) vpa.UpdateMode {
+	if spec.UpdatePolicy != nil && spec.UpdatePolicy.UpdateMode != nil {
+		return *spec.UpdatePolicy.UpdateMode
+	}
+	return vpa.UpdateModeAuto
+}
+
+// containerPolicy returns the policy of the named container in resourcePolicy, adding it if needed.
+func containerPolicy(resourcePolicy *vpa.PodResourcePolicy, containerName string) *vpa.ContainerResourcePolicy {
+	for i := range resourcePolicy.ContainerPolicies {
+		if resourcePolicy.ContainerPolicies[i].ContainerName == containerName {
+			return &resourcePolicy.ContainerPolicies[i]
+		}
+	}
+	resourcePolicy.ContainerPolicies = append(resourcePolicy.ContainerPolicies,
+		vpa.ContainerResourcePolicy{ContainerName: containerName})
+	return &resourcePolicy.ContainerPolicies[len(resourcePolicy.ContainerPolicies)-1]
+}
+
+// computeResources evaluates the expressions of every resource, and merges the results into resources.
+func computeResources(
+	compute computeFunc,
+	path string,
+	exprs map[corev1.ResourceName]string,
+	resources corev1.ResourceList,
+) (corev1.ResourceList, error) {
+	if len(exprs) == 0 {
+		return resources, nil
+	}
+	if resources == nil {
+		resources = corev1.ResourceList{}
+	}
+	for name, expression := range exprs {
+		resourcePath := fmt.Sprintf("%s[%s]", path, name)
+		value, err := compute(resourcePath, expression)
+		if err != nil {
+			return nil, err
+		}
+		quantity, err := toQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", resourcePath, err)
+		}
+		resources[name] = quantity
+	}
+	return resources, nil
+}
+
+// toQuantity converts the result of an expression to a quantity.
+// Strings are parsed as quantities, numbers are taken in the base unit of the resource.
+func toQuantity(value interface{}) (resource.Quantity, error) {
+	switch v := value.(type) {
+	case resource.Quantity:
+		return v, nil
+	case *resource.Quantity:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return resource.Quantity{}, fmt.Errorf("%q is not a valid quantity: %w", v, err)
+		}
+		return q, nil
+	case int:
+		return *resource.NewQuantity(int64(v), resource.DecimalSI), nil
+	case int64:
+		return *resource.NewQuantity(v, resource.DecimalSI), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return resource.Quantity{}, fmt.Errorf("%v is not a valid quantity", v)
+		}
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return *resource.NewQuantity(int64(v), resource.DecimalSI), nil
+		}
+		return *resource.NewMilliQuantity(int64(math.Round(v*1000)), resource.DecimalSI), nil
+	}
+	return resource.Quantity{}, fmt.Errorf("expression returned %T, expected a quantity or a number", value)
+}
+
+// toUpdateMode converts the result of an expression to an update mode.
+func toUpdateMode(value interface{}) (vpa.UpdateMode, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expression returned %T, expected a string", value)
+	}
+	switch mode := vpa.UpdateMode(s); mode {
+	case vpa.UpdateModeOff, vpa.UpdateModeInitial, vpa.UpdateModeRecreate, vpa.UpdateModeAuto:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%q is not a valid update mode", s)
+}
+
+// toResourceNames converts the result of an expression to a list of resource names.
+func toResourceNames(value interface{}) ([]corev1.ResourceName, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case []string:
+		for _, item := range v {
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("expression returned %T, expected a list of resource names", value)
+	}
+
+	names := make([]corev1.ResourceName, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("expression returned a list of %T, expected a list of resource names", item)
+		}
+		switch name := corev1.ResourceName(s); name {
+		case corev1.ResourceCPU, corev1.ResourceMemory:
+			names = append(names, name)
+		default:
+			return nil, fmt.Errorf("%q is not a valid resource name, expected %q or %q", s, corev1.ResourceCPU, corev1.ResourceMemory)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("makeVpaSpec", func() {
+	targetRef := &autoscaling.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "example"}
+	env := programEnv(
+		map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name": "app",
+								"resources": map[string]interface{}{
+									"limits": map[string]interface{}{"memory": "512Mi"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		map[string]interface{}{},
+		map[string]interface{}{},
+	)
+	compute := func(language v1alpha1.ConditionLanguage) computeFunc {
+		return func(_, expression string) (interface{}, error) {
+			p, err := compileValue(language, expression, env)
+			if err != nil {
+				return nil, err
+			}
+			return p.eval(env)
+		}
+	}
+
+	It("should copy the static fields", func() {
+		wantSpec := &v1alpha1.VpaSpec{UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff}}
+		spec, err := makeVpaSpec(targetRef, wantSpec, compute(v1alpha1.ConditionLanguageExpr))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.TargetRef).To(Equal(targetRef))
+		Expect(spec.UpdatePolicy).To(Equal(wantSpec.UpdatePolicy))
+	})
+
+	DescribeTable("should compute the fields from the target",
+		func(language v1alpha1.ConditionLanguage, maxMemory string) {
+			wantSpec := &v1alpha1.VpaSpec{
+				UpdatePolicy: &vpa.PodUpdatePolicy{UpdateMode: &updateModeOff},
+				ResourcePolicy: &vpa.PodResourcePolicy{
+					ContainerPolicies: []vpa.ContainerResourcePolicy{{
+						ContainerName: "app",
+						MinAllowed:    corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+					}},
+				},
+				Expressions: &v1alpha1.VpaSpecExpressions{
+					UpdateMode: `"Initial"`,
+					ContainerPolicies: []v1alpha1.ContainerPolicyExpressions{{
+						ContainerName:       "app",
+						MaxAllowed:          map[corev1.ResourceName]string{corev1.ResourceMemory: maxMemory},
+						ControlledResources: `["memory"]`,
+					}},
+				},
+			}
+			spec, err := makeVpaSpec(targetRef, wantSpec, compute(language))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(*spec.UpdatePolicy.UpdateMode).To(Equal(vpa.UpdateModeInitial))
+			Expect(spec.ResourcePolicy.ContainerPolicies).To(HaveLen(1))
+			policy := spec.ResourcePolicy.ContainerPolicies[0]
+			Expect(policy.MinAllowed.Cpu().String()).To(Equal("10m"))
+			Expect(policy.MaxAllowed.Memory().Value()).To(Equal(int64(1024 * 1024 * 1024)))
+			Expect(*policy.ControlledResources).To(Equal([]corev1.ResourceName{corev1.ResourceMemory}))
+
+			By("leaving the policy unchanged")
+			Expect(*wantSpec.UpdatePolicy.UpdateMode).To(Equal(vpa.UpdateModeOff))
+			Expect(wantSpec.ResourcePolicy.ContainerPolicies[0].MaxAllowed).To(BeNil())
+		},
+		Entry("expr", v1alpha1.ConditionLanguageExpr,
+			`int(trimSuffix(target.spec.template.spec.containers[0].resources.limits.memory, "Mi")) * 2 * 1024 * 1024`),
+		Entry("cel", v1alpha1.ConditionLanguageCEL,
+			`int(target.spec.template.spec.containers[0].resources.limits.memory.replace("Mi", "")) * 2 * 1024 * 1024`),
+	)
+
+	It("should report invalid results with the path of the field", func() {
+		wantSpec := &v1alpha1.VpaSpec{
+			Expressions: &v1alpha1.VpaSpecExpressions{
+				ContainerPolicies: []v1alpha1.ContainerPolicyExpressions{{
+					ContainerName: "app",
+					MinAllowed:    map[corev1.ResourceName]string{corev1.ResourceMemory: `"lots"`},
+				}},
+			},
+		}
+		_, err := makeVpaSpec(targetRef, wantSpec, compute(v1alpha1.ConditionLanguageExpr))
+		Expect(err).To(MatchError(ContainSubstring(`vpaSpec.expressions.containerPolicies[0].minAllowed[memory]: "lots" is not a valid quantity`)))
+	})
+})
+
+var _ = Describe("VpaSpec conversions", func() {
+	DescribeTable("toQuantity",
+		func(value interface{}, want string) {
+			q, err := toQuantity(value)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(q.Cmp(resource.MustParse(want))).To(BeZero())
+		},
+		Entry("string", "256Mi", "256Mi"),
+		Entry("int", 2, "2"),
+		Entry("int64", int64(1024), "1024"),
+		Entry("whole float64", float64(3), "3"),
+		Entry("fractional float64", 0.25, "250m"),
+	)
+
+	It("should reject values that are not quantities", func() {
+		_, err := toQuantity(true)
+		Expect(err).To(HaveOccurred())
+		_, err = toQuantity("1 gigabyte")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should only accept the update modes of VerticalPodAutoscalers", func() {
+		mode, err := toUpdateMode("Recreate")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal(vpa.UpdateModeRecreate))
+		_, err = toUpdateMode("Sometimes")
+		Expect(err).To(HaveOccurred())
+		_, err = toUpdateMode(1)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should only accept cpu and memory as controlled resources", func() {
+		names, err := toResourceNames([]interface{}{"cpu", "memory"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}))
+		_, err = toResourceNames([]interface{}{"storage"})
+		Expect(err).To(HaveOccurred())
+		_, err = toResourceNames("cpu")
+		Expect(err).To(HaveOccurred())
+	})
+})
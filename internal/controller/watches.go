@@ -0,0 +1,196 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// targetRefIndexKey is the field index used to find the DynamicVerticalPodAutoscalers referencing a target.
+const targetRefIndexKey = ".spec.targetRef"
+
+// targetRefIndexValue returns the value indexed under targetRefIndexKey for a target.
+// The version is omitted so that a target is found regardless of the apiVersion used to reference it.
+func targetRefIndexValue(gk schema.GroupKind, name string) string {
+	return gk.String() + "/" + name
+}
+
+// indexTargetRef is the client.IndexerFunc for targetRefIndexKey.
+func indexTargetRef(o client.Object) []string {
+	obj, ok := o.(*v1alpha1.DynamicVerticalPodAutoscaler)
+	if !ok || obj.Spec.TargetRef == nil {
+		return nil
+	}
+	gv, err := schema.ParseGroupVersion(obj.Spec.TargetRef.APIVersion)
+	if err != nil {
+		return nil
+	}
+	return []string{targetRefIndexValue(gv.WithKind(obj.Spec.TargetRef.Kind).GroupKind(), obj.Spec.TargetRef.Name)}
+}
+
+// targetKindsIndexKey is the field index used to find the DynamicVerticalPodAutoscalers selecting a kind of target.
+const targetKindsIndexKey = ".spec.targetSelector.kinds"
+
+// indexTargetKinds is the client.IndexerFunc for targetKindsIndexKey.
+func indexTargetKinds(o client.Object) []string {
+	obj, ok := o.(*v1alpha1.DynamicVerticalPodAutoscaler)
+	if !ok || obj.Spec.TargetSelector == nil {
+		return nil
+	}
+	var kinds []string
+	for _, kind := range obj.Spec.TargetSelector.Kinds {
+		gv, err := schema.ParseGroupVersion(kind.APIVersion)
+		if err != nil {
+			continue
+		}
+		kinds = append(kinds, gv.WithKind(kind.Kind).GroupKind().String())
+	}
+	return kinds
+}
+
+// targetPredicate filters the events of the targets. The status of a workload changes on every step of its
+// rollouts, which must not trigger a reconciliation of every object targeting it. Fields read from the
+// status, such as the replicas of a DaemonSet, are refreshed by the periodic resync.
+var targetPredicate = predicate.Or(
+	predicate.GenerationChangedPredicate{},
+	predicate.LabelChangedPredicate{},
+	predicate.AnnotationChangedPredicate{},
+)
+
+// namespacePredicate filters the events of the namespaces to the changes of the labels and annotations,
+// which are exposed to the conditions by the namespace variable.
+var namespacePredicate = predicate.Or(
+	predicate.LabelChangedPredicate{},
+	predicate.AnnotationChangedPredicate{},
+)
+
+// targetWatcher starts watches on the kinds of targets, as they are discovered.
+type targetWatcher struct {
+	controller controller.Controller
+	cache      cache.Cache
+	restMapper meta.RESTMapper
+	handler    handler.EventHandler
+
+	mu    sync.Mutex
+	kinds map[schema.GroupKind]struct{}
+}
+
+// newTargetWatcher returns a targetWatcher sending the events of the targets to the handler of c.
+func newTargetWatcher(mgr ctrl.Manager, c controller.Controller, h handler.EventHandler) *targetWatcher {
+	return &targetWatcher{
+		controller: c,
+		cache:      mgr.GetCache(),
+		restMapper: mgr.GetRESTMapper(),
+		handler:    h,
+		kinds:      map[schema.GroupKind]struct{}{},
+	}
+}
+
+// ensure starts watching the given kind of target, unless it is already watched.
+// It is a no-op on a nil targetWatcher, which is the case when the reconciler was not set up with a manager.
+func (w *targetWatcher) ensure(ctx context.Context, gvk schema.GroupVersionKind) error {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.kinds[gvk.GroupKind()]; ok {
+		return nil
+	}
+
+	// Fail early for unknown kinds, the source would otherwise retry in the background.
+	if _, err := w.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Watching target kind", "gvk", gvk)
+
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(gvk)
+	if err := w.controller.Watch(source.Kind(w.cache, target), w.handler, targetPredicate); err != nil {
+		return err
+	}
+
+	w.kinds[gvk.GroupKind()] = struct{}{}
+	return nil
+}
+
+// ensureTargetWatch starts watching the given kind of target, unless it is already watched.
+func (r *DynamicVerticalPodAutoscalerReconciler) ensureTargetWatch(ctx context.Context, gvk schema.GroupVersionKind) error {
+	return r.targetWatcher.ensure(ctx, gvk)
+}
+
+// findObjectsForTarget maps a target to the DynamicVerticalPodAutoscalers referencing or selecting it.
+// On updates, it is called for both the old and the new object, so that a target
+// that stops matching a targetSelector is also mapped.
+func (r *DynamicVerticalPodAutoscalerReconciler) findObjectsForTarget(ctx context.Context, target client.Object) []reconcile.Request {
+	logger := log.FromContext(ctx)
+	gk := target.GetObjectKind().GroupVersionKind().GroupKind()
+
+	var referencing v1alpha1.DynamicVerticalPodAutoscalerList
+	if err := r.List(ctx, &referencing,
+		client.InNamespace(target.GetNamespace()),
+		client.MatchingFields{targetRefIndexKey: targetRefIndexValue(gk, target.GetName())},
+	); err != nil {
+		logger.Error(err, "Unable to list DynamicVerticalPodAutoscalers for target",
+			"kind", gk, "name", target.GetName())
+		return nil
+	}
+
+	var selecting v1alpha1.DynamicVerticalPodAutoscalerList
+	if err := r.List(ctx, &selecting,
+		client.InNamespace(target.GetNamespace()),
+		client.MatchingFields{targetKindsIndexKey: gk.String()},
+	); err != nil {
+		logger.Error(err, "Unable to list DynamicVerticalPodAutoscalers for target",
+			"kind", gk, "name", target.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(referencing.Items)+len(selecting.Items))
+	for _, item := range referencing.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	for _, item := range selecting.Items {
+		selector, err := metav1.LabelSelectorAsSelector(item.Spec.TargetSelector.Selector)
+		if err != nil || !selector.Matches(labels.Set(target.GetLabels())) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	return requests
+}
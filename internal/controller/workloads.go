@@ -0,0 +1,294 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+// workload describes how the pod template and the desired number of replicas of a kind of workload are read.
+type workload struct {
+	// podTemplate is the path of the pod template in the object.
+	podTemplate []string
+	// replicas is the path of the desired number of replicas in the object.
+	// The replicas are read from the scale subresource when nil.
+	replicas []string
+	// podOwner is the kind of the objects controlled by the workload which control its Pods, if any.
+	podOwner schema.GroupKind
+}
+
+var (
+	replicaSetGroupKind = schema.GroupKind{Group: appsv1.GroupName, Kind: "ReplicaSet"}
+	jobGroupKind        = schema.GroupKind{Group: batchv1.GroupName, Kind: "Job"}
+)
+
+// builtinWorkloads are the built-in kinds of workloads supported by the VerticalPodAutoscaler.
+var builtinWorkloads = map[schema.GroupKind]workload{
+	{Group: appsv1.GroupName, Kind: "Deployment"}: {
+		podTemplate: []string{"spec", "template"},
+		replicas:    []string{"spec", "replicas"},
+		podOwner:    replicaSetGroupKind,
+	},
+	{Group: appsv1.GroupName, Kind: "StatefulSet"}: {
+		podTemplate: []string{"spec", "template"},
+		replicas:    []string{"spec", "replicas"},
+	},
+	replicaSetGroupKind: {
+		podTemplate: []string{"spec", "template"},
+		replicas:    []string{"spec", "replicas"},
+	},
+	{Group: appsv1.GroupName, Kind: "DaemonSet"}: {
+		podTemplate: []string{"spec", "template"},
+		replicas:    []string{"status", "desiredNumberScheduled"},
+	},
+	jobGroupKind: {
+		podTemplate: []string{"spec", "template"},
+		replicas:    []string{"spec", "parallelism"},
+	},
+	{Group: batchv1.GroupName, Kind: "CronJob"}: {
+		podTemplate: []string{"spec", "jobTemplate", "spec", "template"},
+		replicas:    []string{"spec", "jobTemplate", "spec", "parallelism"},
+		podOwner:    jobGroupKind,
+	},
+	{Group: corev1.GroupName, Kind: "ReplicationController"}: {
+		podTemplate: []string{"spec", "template"},
+		replicas:    []string{"spec", "replicas"},
+	},
+}
+
+// scaleWorkload describes the other kinds of workloads, which must have a scale subresource,
+// such as Argo Rollouts. Their Pods may be controlled by ReplicaSets.
+var scaleWorkload = workload{
+	podTemplate: []string{"spec", "template"},
+	podOwner:    replicaSetGroupKind,
+}
+
+// DefaultAllowedKinds are the kinds of workloads which can be targeted when no allowed kinds are configured:
+// the built-in kinds supported by the VerticalPodAutoscaler.
+var DefaultAllowedKinds = []schema.GroupKind{
+	{Group: appsv1.GroupName, Kind: "Deployment"},
+	{Group: appsv1.GroupName, Kind: "StatefulSet"},
+	{Group: appsv1.GroupName, Kind: "ReplicaSet"},
+	{Group: appsv1.GroupName, Kind: "DaemonSet"},
+	{Group: batchv1.GroupName, Kind: "Job"},
+	{Group: batchv1.GroupName, Kind: "CronJob"},
+	{Group: corev1.GroupName, Kind: "ReplicationController"},
+}
+
+// ParseAllowedKinds parses a comma-separated list of kinds, written Kind.group, such as Rollout.argoproj.io,
+// or Kind for the core group. The list replaces DefaultAllowedKinds, the built-in kinds must be listed to
+// remain allowed. The kinds which are not built-in must have a scale subresource, see CheckAllowedKinds.
+func ParseAllowedKinds(value string) ([]schema.GroupKind, error) {
+	var kinds []schema.GroupKind
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		kind := schema.ParseGroupKind(item)
+		if len(kind.Kind) == 0 {
+			return nil, fmt.Errorf("invalid kind %q, expected Kind.group", item)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// CheckAllowedKinds checks with the discovery API of the server of mgr that the allowed kinds which are not
+// built-in are served with a scale subresource, from which their replicas are read.
+func CheckAllowedKinds(mgr ctrl.Manager, kinds []schema.GroupKind) error {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	return checkScaleSubresources(mgr.GetRESTMapper(), discoveryClient, kinds)
+}
+
+// checkScaleSubresources checks that the kinds which are not built-in are served with a scale subresource.
+func checkScaleSubresources(restMapper meta.RESTMapper, discoveryClient discovery.DiscoveryInterface, kinds []schema.GroupKind) error {
+	var errs []error
+	for _, kind := range kinds {
+		if _, ok := builtinWorkloads[kind]; ok {
+			continue
+		}
+		mapping, err := restMapper.RESTMapping(kind)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allowed kind %s: %w", kind, err))
+			continue
+		}
+		resources, err := discoveryClient.ServerResourcesForGroupVersion(mapping.Resource.GroupVersion().String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allowed kind %s: %w", kind, err))
+			continue
+		}
+		if !slices.ContainsFunc(resources.APIResources, func(resource metav1.APIResource) bool {
+			return resource.Name == mapping.Resource.Resource+"/scale"
+		}) {
+			errs = append(errs, fmt.Errorf("allowed kind %s has no scale subresource", kind))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// workloadKinds are the kinds of workloads which can be targeted.
+type workloadKinds map[schema.GroupKind]workload
+
+// newWorkloadKinds returns the workloadKinds of the allowed kinds, defaulted to DefaultAllowedKinds.
+func newWorkloadKinds(allowed []schema.GroupKind) workloadKinds {
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedKinds
+	}
+	kinds := make(workloadKinds, len(allowed))
+	for _, kind := range allowed {
+		if w, ok := builtinWorkloads[kind]; ok {
+			kinds[kind] = w
+		} else {
+			kinds[kind] = scaleWorkload
+		}
+	}
+	return kinds
+}
+
+// get returns the workload of kind, which is described as a scaleWorkload when it is not allowed.
+func (k workloadKinds) get(kind schema.GroupKind) workload {
+	if w, ok := k[kind]; ok {
+		return w
+	}
+	return scaleWorkload
+}
+
+// validateKind checks that the kind referenced by apiVersion and kind is allowed.
+// An invalid apiVersion or an empty kind is reported by the caller.
+func (k workloadKinds) validateKind(apiVersion, kind string, path *field.Path) field.ErrorList {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil || len(kind) == 0 {
+		return nil
+	}
+	if _, ok := k[gv.WithKind(kind).GroupKind()]; ok {
+		return nil
+	}
+	allowed := make([]string, 0, len(k))
+	for gk := range k {
+		allowed = append(allowed, gk.String())
+	}
+	sort.Strings(allowed)
+	return field.ErrorList{field.NotSupported(path, gv.WithKind(kind).GroupKind().String(), allowed)}
+}
+
+// validateTargetKinds checks that the kinds of a targetSelector are allowed.
+func (k workloadKinds) validateTargetKinds(kinds []v1alpha1.TargetKind, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, kind := range kinds {
+		errs = append(errs, k.validateKind(kind.APIVersion, kind.Kind, path.Index(i).Child("kind"))...)
+	}
+	return errs
+}
+
+// targetValue returns the target variable of the programs: the target object with its normalised
+// podTemplate and replicas, which are read according to its kind.
+func (t *targetReconciler) targetValue(ctx context.Context, target *unstructured.Unstructured) (map[string]interface{}, error) {
+	w := t.workloads.get(target.GroupVersionKind().GroupKind())
+
+	value := make(map[string]interface{}, len(target.Object)+2)
+	for key, v := range target.Object {
+		value[key] = v
+	}
+
+	value["podTemplate"] = nil
+	if podTemplate, found, _ := unstructured.NestedFieldNoCopy(target.Object, w.podTemplate...); found {
+		if _, ok := podTemplate.(map[string]interface{}); ok {
+			value["podTemplate"] = podTemplate
+		}
+	}
+
+	replicas, err := t.workloadReplicas(ctx, w, target)
+	if err != nil {
+		return nil, err
+	}
+	value["replicas"] = replicas
+	return value, nil
+}
+
+// workloadReplicas returns the desired number of replicas of target, defaulted to 1.
+func (t *targetReconciler) workloadReplicas(ctx context.Context, w workload, target *unstructured.Unstructured) (int64, error) {
+	if w.replicas != nil {
+		if replicas, found, _ := unstructured.NestedInt64(target.Object, w.replicas...); found {
+			return replicas, nil
+		}
+		return 1, nil
+	}
+
+	scale := &unstructured.Unstructured{}
+	scale.SetGroupVersionKind(schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"})
+	if err := t.SubResource("scale").Get(ctx, target, scale); err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, withReason(v1alpha1.ReasonInvalidSpec,
+				fmt.Errorf("%s %s has no scale subresource: %w", target.GetKind(), target.GetName(), err))
+		}
+		return 0, err
+	}
+	if replicas, found, _ := unstructured.NestedInt64(scale.Object, "spec", "replicas"); found {
+		return replicas, nil
+	}
+	return 1, nil
+}
+
+// listControlled returns the UIDs of the objects of kind controlled by owner in namespace.
+// The objects are listed from the cache with controllerIndexKey, which must have been registered.
+func (t *targetReconciler) listControlled(ctx context.Context, kind schema.GroupKind, namespace string, owner string) ([]string, error) {
+	opts := []client.ListOption{client.InNamespace(namespace), client.MatchingFields{controllerIndexKey: owner}}
+	var uids []string
+	switch kind {
+	case replicaSetGroupKind:
+		var list appsv1.ReplicaSetList
+		if err := t.List(ctx, &list, opts...); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			uids = append(uids, string(list.Items[i].UID))
+		}
+	case jobGroupKind:
+		var list batchv1.JobList
+		if err := t.List(ctx, &list, opts...); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			uids = append(uids, string(list.Items[i].UID))
+		}
+	}
+	return uids, nil
+}
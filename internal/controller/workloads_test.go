@@ -0,0 +1,146 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/stackrox/dynamic-vertical-pod-autoscaler/api/v1alpha1"
+)
+
+var _ = Describe("Workloads", func() {
+	It("should parse the allowed kinds", func() {
+		kinds, err := ParseAllowedKinds("Deployment.apps, Rollout.argoproj.io,ReplicationController,")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds).To(Equal([]schema.GroupKind{
+			{Group: "apps", Kind: "Deployment"},
+			{Group: "argoproj.io", Kind: "Rollout"},
+			{Kind: "ReplicationController"},
+		}))
+
+		_, err = ParseAllowedKinds(".apps")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should only accept the allowed kinds", func() {
+		path := field.NewPath("kind")
+		kinds := newWorkloadKinds(nil)
+		Expect(kinds.validateKind("batch/v1", "CronJob", path)).To(BeEmpty())
+		Expect(kinds.validateKind("argoproj.io/v1alpha1", "Rollout", path)).To(HaveLen(1))
+		Expect(kinds.validateTargetKinds([]v1alpha1.TargetKind{
+			{APIVersion: "apps/v1", Kind: "DaemonSet"},
+			{APIVersion: "v1", Kind: "Pod"},
+		}, path)).To(HaveLen(1))
+
+		kinds = newWorkloadKinds([]schema.GroupKind{{Group: "argoproj.io", Kind: "Rollout"}})
+		Expect(kinds.validateKind("argoproj.io/v1alpha1", "Rollout", path)).To(BeEmpty())
+		Expect(kinds.validateKind("apps/v1", "Deployment", path)).To(HaveLen(1))
+		Expect(kinds.get(schema.GroupKind{Group: "argoproj.io", Kind: "Rollout"}).replicas).To(BeNil())
+	})
+
+	It("should check the scale subresource of the allowed kinds", func() {
+		restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "argoproj.io", Version: "v1alpha1"}, {Group: "example.com", Version: "v1"}})
+		restMapper.Add(schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}, meta.RESTScopeNamespace)
+		restMapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace)
+		discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "argoproj.io/v1alpha1",
+				APIResources: []metav1.APIResource{{Name: "rollouts"}, {Name: "rollouts/scale"}, {Name: "rollouts/status"}},
+			},
+			{
+				GroupVersion: "example.com/v1",
+				APIResources: []metav1.APIResource{{Name: "widgets"}},
+			},
+		}}}
+
+		Expect(checkScaleSubresources(restMapper, discoveryClient, DefaultAllowedKinds)).To(Succeed())
+		Expect(checkScaleSubresources(restMapper, discoveryClient, []schema.GroupKind{
+			{Group: "apps", Kind: "Deployment"},
+			{Group: "argoproj.io", Kind: "Rollout"},
+		})).To(Succeed())
+		Expect(checkScaleSubresources(restMapper, discoveryClient, []schema.GroupKind{
+			{Group: "example.com", Kind: "Widget"},
+		})).To(MatchError(ContainSubstring("Widget.example.com has no scale subresource")))
+		Expect(checkScaleSubresources(restMapper, discoveryClient, []schema.GroupKind{
+			{Group: "example.com", Kind: "Gadget"},
+		})).To(MatchError(ContainSubstring("Gadget.example.com")))
+	})
+
+	It("should normalise the pod template and the replicas of the target", func() {
+		t := &targetReconciler{workloads: newWorkloadKinds(nil)}
+		cronJob := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "CronJob",
+			"spec": map[string]interface{}{
+				"jobTemplate": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"parallelism": int64(3),
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"containers": []interface{}{map[string]interface{}{"name": "app"}},
+							},
+						},
+					},
+				},
+			},
+		}}
+		value, err := t.targetValue(context.Background(), cronJob)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(HaveKeyWithValue("kind", "CronJob"))
+		Expect(value).To(HaveKey("podTemplate"))
+		Expect(value).To(HaveKeyWithValue("replicas", int64(3)))
+		Expect(templateContainer(value, "app")).NotTo(BeNil())
+		Expect(targetReplicas(value)).To(Equal(int64(3)))
+		Expect(cronJob.Object).NotTo(HaveKey("podTemplate"))
+
+		daemonSet := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "DaemonSet",
+			"status":     map[string]interface{}{"desiredNumberScheduled": int64(4)},
+		}}
+		value, err = t.targetValue(context.Background(), daemonSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(HaveKeyWithValue("podTemplate", BeNil()))
+		Expect(value).To(HaveKeyWithValue("replicas", int64(4)))
+	})
+
+	DescribeTable("should expose the normalised fields to the conditions",
+		func(language v1alpha1.ConditionLanguage, condition string) {
+			env := programEnv(map[string]interface{}{
+				"podTemplate": map[string]interface{}{"metadata": map[string]interface{}{}},
+				"replicas":    int64(2),
+			}, nil, nil)
+			p, err := compileCondition(language, condition, env)
+			Expect(err).NotTo(HaveOccurred())
+			matched, err := p.run(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+		},
+		Entry("expr", v1alpha1.ConditionLanguageExpr, `target.replicas == 2 && target.podTemplate != nil`),
+		Entry("cel", v1alpha1.ConditionLanguageCEL, `target.replicas == 2 && target.podTemplate != null`),
+	)
+})